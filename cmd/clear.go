@@ -0,0 +1,210 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var (
+	clearAllP4   bool
+	clearCascade bool
+)
+
+// clearCmd represents the clear command
+var clearCmd = &cobra.Command{
+	Use:   "clear TABLE-NAME...",
+	Short: "Remove all flows of tables",
+	Long: `Remove all flows of tables and reset their default entries.
+
+Tables which reference action profile members or selector groups through
+DependsOn are cleared before the profile and selector tables themselves.
+Clearing a table which other tables depend on is refused unless those tables
+are cleared as well, either by naming them or with --cascade.
+
+  bfcli clear SwitchIngress.ipv4_lpm
+  bfcli clear SwitchIngress.nexthop_profile --cascade
+  bfcli clear --all-p4`,
+	ValidArgsFunction: completeTableNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, p4Info, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		if len(args) == 0 && !clearAllP4 {
+			fmt.Println("Please specify tables to clear or --all-p4")
+			exit(1)
+		}
+
+		selected := map[uint32]bool{}
+		if clearAllP4 {
+			for _, t := range p4Info.Tables {
+				selected[t.ID] = true
+			}
+		}
+		for _, name := range args {
+			table := findTable(name)
+			if table == nil {
+				fmt.Printf("Can not found table with name: %s\n", name)
+				exit(1)
+			}
+			if !hasTable(p4Info, table.ID) {
+				fmt.Printf("Table %s is not a P4 table and can not be cleared\n", table.Name)
+				exit(1)
+			}
+			selected[table.ID] = true
+		}
+
+		order, err := clearOrder(p4Info, selected, clearCascade)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		failed := 0
+		for _, table := range order {
+			if err := clearTable(cli, ctx, table); err != nil {
+				fmt.Printf("Failed to clear %s: %v\n", table.Name, err)
+				failed++
+				continue
+			}
+			fmt.Printf("Table %s is cleared\n", table.Name)
+		}
+		if failed != 0 {
+			conn.Close()
+			cancel()
+			exit(1)
+		}
+	},
+}
+
+// tableDependents maps each table ID to the tables which depend on it.
+func tableDependents(info *util.BfRtInfoStruct) map[uint32][]*util.Table {
+	dependents := map[uint32][]*util.Table{}
+	for i := range info.Tables {
+		for _, dep := range info.Tables[i].DependsOn {
+			dependents[uint32(dep)] = append(dependents[uint32(dep)], &info.Tables[i])
+		}
+	}
+	return dependents
+}
+
+// hasTable reports whether the table ID belongs to the BfRt info.
+func hasTable(info *util.BfRtInfoStruct, id uint32) bool {
+	for i := range info.Tables {
+		if info.Tables[i].ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// clearOrder returns the selected tables ordered so that every table comes
+// before the tables it depends on. Tables depending on a selected table are
+// added with cascade, otherwise they make the clear fail.
+func clearOrder(info *util.BfRtInfoStruct, selected map[uint32]bool, cascade bool) ([]*util.Table, error) {
+	dependents := tableDependents(info)
+
+	pending := make([]uint32, 0, len(selected))
+	for id := range selected {
+		pending = append(pending, id)
+	}
+	for len(pending) != 0 {
+		id := pending[0]
+		pending = pending[1:]
+		var blocking []string
+		for _, d := range dependents[id] {
+			if selected[d.ID] {
+				continue
+			}
+			if !cascade {
+				blocking = append(blocking, d.Name)
+				continue
+			}
+			selected[d.ID] = true
+			pending = append(pending, d.ID)
+		}
+		if len(blocking) != 0 {
+			name := fmt.Sprintf("%d", id)
+			if t := findTableById(id); t != nil {
+				name = t.Name
+			}
+			return nil, fmt.Errorf("table %s is referenced by %s, clear them too or use --cascade", name, strings.Join(blocking, ", "))
+		}
+	}
+
+	var order []*util.Table
+	visited := map[uint32]bool{}
+	var visit func(t *util.Table)
+	visit = func(t *util.Table) {
+		if visited[t.ID] {
+			return
+		}
+		visited[t.ID] = true
+		for _, d := range dependents[t.ID] {
+			if selected[d.ID] {
+				visit(d)
+			}
+		}
+		order = append(order, t)
+	}
+	for i := range info.Tables {
+		if selected[info.Tables[i].ID] {
+			visit(&info.Tables[i])
+		}
+	}
+	return order, nil
+}
+
+// clearTable deletes all entries of a table by a delete without key, then
+// resets the default entry of match tables.
+func clearTable(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table) error {
+	err := writeUpdates(cli, ctx, &p4.Update{
+		Type: p4.Update_DELETE,
+		Entity: &p4.Entity{
+			Entity: &p4.Entity_TableEntry{
+				TableEntry: &p4.TableEntry{TableId: table.ID},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(table.TableType, "MatchAction") {
+		return nil
+	}
+	return writeUpdates(cli, ctx, &p4.Update{
+		Type: p4.Update_DELETE,
+		Entity: &p4.Entity{
+			Entity: &p4.Entity_TableEntry{
+				TableEntry: &p4.TableEntry{TableId: table.ID, IsDefaultEntry: true},
+			},
+		},
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(clearCmd)
+	clearCmd.Flags().BoolVar(&clearAllP4, "all-p4", false, "Clear all P4 tables")
+	clearCmd.Flags().BoolVar(&clearCascade, "cascade", false, "Also clear the tables depending on the given tables")
+}
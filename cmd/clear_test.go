@@ -0,0 +1,75 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/P4Networking/pisc/util"
+	"strings"
+	"testing"
+)
+
+// testClearInfo has a selector table 2 depending on the action profile 1, and
+// the match table 3 depending on the selector.
+const testClearInfo = `{"tables": [
+	{"name": "SwitchIngress.profile", "id": 1, "table_type": "Action"},
+	{"name": "SwitchIngress.selector", "id": 2, "table_type": "Selector", "depends_on": [1]},
+	{"name": "SwitchIngress.ecmp", "id": 3, "table_type": "MatchAction_Indirect_Selector", "depends_on": [2]},
+	{"name": "SwitchIngress.acl", "id": 4, "table_type": "MatchAction_Direct"}
+]}`
+
+func TestClearOrder(t *testing.T) {
+	info := &util.BfRtInfoStruct{}
+	if err := json.Unmarshal([]byte(testClearInfo), info); err != nil {
+		t.Fatalf("invalid info: %v", err)
+	}
+	tests := []struct {
+		name     string
+		selected []uint32
+		cascade  bool
+		want     string
+		wantErr  bool
+	}{
+		{"independent table", []uint32{4}, false, "SwitchIngress.acl", false},
+		{"leaf table", []uint32{3}, false, "SwitchIngress.ecmp", false},
+		{"dependents named", []uint32{1, 2, 3}, false, "SwitchIngress.ecmp,SwitchIngress.selector,SwitchIngress.profile", false},
+		{"cascade", []uint32{1}, true, "SwitchIngress.ecmp,SwitchIngress.selector,SwitchIngress.profile", false},
+		{"blocked by dependent", []uint32{1}, false, "", true},
+		{"blocked by indirect dependent", []uint32{1, 2}, false, "", true},
+	}
+	for _, tt := range tests {
+		selected := map[uint32]bool{}
+		for _, id := range tt.selected {
+			selected[id] = true
+		}
+		order, err := clearOrder(info, selected, tt.cascade)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: clearOrder error = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		names := make([]string, 0, len(order))
+		for _, table := range order {
+			names = append(names, table.Name)
+		}
+		if got := strings.Join(names, ","); got != tt.want {
+			t.Errorf("%s: clearOrder = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	if !hasTable(info, 3) || hasTable(info, 5) {
+		t.Errorf("hasTable does not match the tables of the info")
+	}
+}
@@ -16,33 +16,185 @@ limitations under the License.
 package cmd
 
 import (
-	"os"
-
+	"github.com/P4Networking/pisc/util"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"os"
+	"strings"
 )
 
+type completionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// flagCompletions keeps the completion of flag values for the shell, as
+// cobra does not expose the functions registered on commands.
+var flagCompletions = map[*pflag.Flag]completionFunc{}
+
+// The syntax of key field values by match type, shown as completion hints.
+var matchTypeHints = map[string]string{
+	"Exact":   "exact: value",
+	"LPM":     "lpm: value/prefix-len",
+	"Ternary": "ternary: value&&&mask",
+	"Range":   "range: low..high",
+}
+
 // completionCmd represents the completion command
 var completionCmd = &cobra.Command{
-	Use:   "completion",
-	Short: "Generates bash completion scripts",
-	Long: `To load completion run
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "Generates shell completion scripts",
+	Long: `To load completion of bash run
 
-. <(bfcli completion)`,
+. <(bfcli completion)
+
+For other shells:
+
+bfcli completion zsh > "${fpath[1]}/_bfcli"
+bfcli completion fish | source
+bfcli completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
 	Run: func(cmd *cobra.Command, args []string) {
-		rootCmd.GenBashCompletion(os.Stdout)
+		shell := "bash"
+		if len(args) == 1 {
+			shell = args[0]
+		}
+		switch shell {
+		case "bash":
+			rootCmd.GenBashCompletion(os.Stdout)
+		case "zsh":
+			rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			rootCmd.GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			rootCmd.GenPowerShellCompletion(os.Stdout)
+		default:
+			cmd.PrintErrf("Unsupported shell %s\n", shell)
+		}
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(completionCmd)
+// completeTableName completes the first argument with P4 and non-P4 table
+// names.
+func completeTableName(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeTableNames(cmd, args, toComplete)
+}
+
+// completeTableNames completes every argument with table names.
+func completeTableNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	_, _, conn, cancel, p4Info, nonP4Info := initConfigClient()
+	defer conn.Close()
+	defer cancel()
 
-	// Here you will define your flags and configuration settings.
+	seen := map[string]bool{}
+	var argsList []string
+	for _, info := range []*util.BfRtInfoStruct{p4Info, nonP4Info} {
+		guess, _ := info.GuessTableName(toComplete)
+		for _, t := range info.Tables {
+			if strings.HasPrefix(t.Name, toComplete) {
+				guess = append(guess, t.Name)
+			}
+		}
+		for _, name := range guess {
+			if !seen[name] {
+				seen[name] = true
+				argsList = append(argsList, name)
+			}
+		}
+	}
+	return argsList, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTableArgs completes the table name, then the key fields of the
+// table as "name=" with the syntax of their match type as description.
+func completeTableArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeTableName(cmd, args, toComplete)
+	}
+	if strings.Contains(toComplete, "=") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	_, _, conn, cancel, _, _ := initConfigClient()
+	defer conn.Close()
+	defer cancel()
+
+	table := findTable(args[0])
+	if table == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var argsList []string
+	for _, k := range keyFields(table) {
+		given := false
+		for _, a := range args[1:] {
+			if strings.HasPrefix(a, k.Name+"=") {
+				given = true
+			}
+		}
+		if !given && strings.HasPrefix(k.Name, toComplete) {
+			argsList = append(argsList, k.Name+"=\t"+matchTypeHints[k.MatchType])
+		}
+	}
+	return argsList, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeActionFlag completes --action with the actions of the table.
+func completeActionFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	_, _, conn, cancel, _, _ := initConfigClient()
+	defer conn.Close()
+	defer cancel()
+
+	table := findTable(args[0])
+	if table == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var argsList []string
+	for _, a := range actionSpecs(table) {
+		if strings.HasPrefix(a.Name, toComplete) {
+			argsList = append(argsList, a.Name)
+		}
+	}
+	return argsList, cobra.ShellCompDirectiveNoFileComp
+}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// completionCmd.PersistentFlags().String("foo", "", "A help for foo")
+// completeParamFlag completes --param with the parameters of the action
+// given by --action.
+func completeParamFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	actionName, _ := cmd.Flags().GetString("action")
+	if len(args) == 0 || actionName == "" || strings.Contains(toComplete, "=") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	_, _, conn, cancel, _, _ := initConfigClient()
+	defer conn.Close()
+	defer cancel()
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// completionCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	table := findTable(args[0])
+	if table == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	action, ok := searchAction(table, actionName)
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var argsList []string
+	for _, p := range action.Params {
+		if strings.HasPrefix(p.Name, toComplete) {
+			argsList = append(argsList, p.Name+"=\t"+p.Type)
+		}
+	}
+	return argsList, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// registerFlagCompletion registers the completion of a flag value for both
+// the shell completion scripts and the interactive shell.
+func registerFlagCompletion(cmd *cobra.Command, name string, f completionFunc) {
+	cmd.RegisterFlagCompletionFunc(name, f)
+	flagCompletions[cmd.Flags().Lookup(name)] = f
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
 }
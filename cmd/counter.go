@@ -0,0 +1,120 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+const (
+	COUNTER_TABLE_TYPE = "Counter"
+	COUNTER_INDEX      = "$COUNTER_INDEX"
+)
+
+var (
+	counterIndex  uint32
+	counterRange  string
+	counterFromHw bool
+)
+
+// counterCmd represents the counter command
+var counterCmd = &cobra.Command{
+	Use:   "counter",
+	Short: "Read counter tables",
+	Long:  `Read the indirect counter tables of the P4 program by index`,
+}
+
+var counterReadCmd = &cobra.Command{
+	Use:   "read COUNTER-NAME",
+	Args:  cobra.ExactArgs(1),
+	Short: "Read the values of a counter",
+	Long: `Read the packet and byte values of a counter. The counters are synced from
+hardware before reading.
+
+  bfcli counter read SwitchIngress.cnt --index 5
+  bfcli counter read SwitchIngress.cnt --range 0-15`,
+	ValidArgsFunction: completeCounterName,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTable(args[0])
+		if table == nil {
+			fmt.Printf("Can not found table with name: %s\n", args[0])
+			exit(1)
+		}
+		if table.TableType != COUNTER_TABLE_TYPE {
+			fmt.Printf("The table %s is not a counter but %s\n", table.Name, table.TableType)
+			exit(1)
+		}
+
+		indexes, err := selectIndexes(cmd, table, counterIndex, counterRange)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		results, err := readIndexedEntries(cli, ctx, table, COUNTER_INDEX, "SyncCounters", indexes, counterFromHw)
+		if err != nil {
+			fatalf("Got error when reading counters: %v", err)
+		}
+		if len(results) == 0 {
+			fmt.Printf("The counter %s has no value\n", table.Name)
+			return
+		}
+		data := dataFields(table)
+		for _, tbl := range results {
+			if index, ok := entryIndex(table, COUNTER_INDEX, tbl); ok {
+				fmt.Printf("%-12s: %s\n", "Index", index)
+			}
+			for _, d := range data {
+				values := collectDataValues(tbl.GetData().GetFields(), d.ID)
+				if len(values) == 0 {
+					continue
+				}
+				fmt.Printf("%-12s: %s\n", strings.TrimPrefix(d.Name, "$COUNTER_SPEC_"), values[0])
+			}
+			fmt.Printf("------------------\n")
+		}
+	},
+}
+
+func completeCounterName(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	_, _, conn, cancel, p4Info, _ := initConfigClient()
+	defer conn.Close()
+	defer cancel()
+
+	var argsList []string
+	for _, t := range p4Info.Tables {
+		if t.TableType == COUNTER_TABLE_TYPE && strings.HasPrefix(t.Name, toComplete) {
+			argsList = append(argsList, t.Name)
+		}
+	}
+	return argsList, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(counterCmd)
+	counterCmd.AddCommand(counterReadCmd)
+
+	counterReadCmd.Flags().Uint32VarP(&counterIndex, "index", "i", 0, "The index of counter to read")
+	counterReadCmd.Flags().StringVarP(&counterRange, "range", "r", "", "The index range of counter to read, e.g. 0-15")
+	counterReadCmd.Flags().BoolVar(&counterFromHw, "from-hw", false, "Read the values from hardware instead of software shadow")
+}
@@ -17,35 +17,49 @@ package cmd
 
 import (
 	"fmt"
-
+	"github.com/P4Networking/proto/go/p4"
 	"github.com/spf13/cobra"
 )
 
 // delFlowCmd represents the delFlow command
 var delFlowCmd = &cobra.Command{
-	Use:   "del-flow",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Use:   "del-flow TABLE-NAME KEY=VALUE...",
+	Args:  cobra.MinimumNArgs(2),
+	Short: "Delete a flow from specify table",
+	Long: `Delete the flow with the given match key from specify table.
+
+  bfcli del-flow SwitchIngress.ipv4_lpm ipv4_dst=10.0.0.0/8`,
+	ValidArgsFunction: completeTableArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("delFlow called")
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTable(args[0])
+		if table == nil {
+			fmt.Printf("Can not found table with name: %s\n", args[0])
+			exit(1)
+		}
+
+		key, err := buildTableKey(table, args[1:])
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		err = writeUpdates(cli, ctx, &p4.Update{
+			Type:   p4.Update_DELETE,
+			Entity: tableEntryEntity(table, key, nil),
+		})
+		if err != nil {
+			fatalf("Got error when deleting flow: %v", err)
+		}
+		fmt.Printf("The flow is deleted from %s\n", table.Name)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(delFlowCmd)
-
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// delFlowCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// delFlowCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
@@ -16,31 +16,54 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"github.com/P4Networking/pisc/util"
 	"github.com/P4Networking/proto/go/p4"
 	"github.com/spf13/cobra"
-	"io"
-	"log"
+	"math/big"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
+)
+
+var (
+	dumpFromHw bool
+	dumpMatch  []string
+	dumpAction string
+	dumpLimit  int
+	dumpOffset int
+	dumpCount  bool
+	dumpWatch  time.Duration
+	dumpOutput string
 )
 
 // dumpCmd represents the dump command
 var dumpCmd = &cobra.Command{
-	Use:   "dump TABLE-NAME",
-	Args:  cobra.ExactArgs(1),
+	Use:   "dump TABLE-NAME [KEY=VALUE...]",
+	Args:  cobra.MinimumNArgs(1),
 	Short: "Dump the existed flows in specify table",
-	Long:  `Display all existed flows in specify table`,
-	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		_, _, conn, cancel, p4Info, _ := initConfigClient()
-		defer conn.Close()
-		defer cancel()
+	Long: `Display all existed flows in specify table.
 
-		argsList, _ := p4Info.GuessTableName(toComplete)
+With match key arguments, only the flow with the given key is read from the
+device. With --match, the flows are filtered locally: an LPM field matches when
+its prefix contains the value, a ternary field when the value matches under
+its mask and a range field when the value is within its bounds.
 
-		return argsList, cobra.ShellCompDirectiveNoFileComp
-	},
+  bfcli dump SwitchIngress.ipv4_lpm ipv4_dst=10.0.0.0/8
+  bfcli dump SwitchIngress.ipv4_lpm --match ipv4_dst=10.1.2.3 --action set_nexthop
+  bfcli dump SwitchIngress.ipv4_lpm --offset 100 --limit 20
+  bfcli dump SwitchIngress.ipv4_lpm --count
+
+With --watch, the table is read on the interval and the flows added, removed
+or modified since the previous read are highlighted, with the counter deltas.
+The first read is the baseline, shown as it is. With -o json, every later
+change is written as a JSON event per line instead.
+
+  bfcli dump SwitchIngress.ipv4_lpm --watch 2s
+  bfcli dump SwitchIngress.ipv4_lpm --watch 2s -o json`,
+	ValidArgsFunction: completeTableArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		tableName := args[0]
 
@@ -57,77 +80,274 @@ var dumpCmd = &cobra.Command{
 				tableName = tableList[0]
 			} else {
 				fmt.Printf("Can not found table with name: %s\n", tableName)
-				return
+				exit(1)
 			}
 		}
+		table := findTable(tableName)
+		if table == nil {
+			fmt.Printf("Can not found table with name: %s\n", tableName)
+			exit(1)
+		}
 
-		req := &p4.ReadRequest{
-			Entities: []*p4.Entity{
-				{
-					Entity: &p4.Entity_TableEntry{
-						TableEntry: &p4.TableEntry{
-							TableId: p4Info.SearchTableId(tableName),
-						},
-					},
-				},
-			},
+		var key *p4.TableKey
+		if len(args) > 1 {
+			var err error
+			key, err = buildTableKey(table, args[1:])
+			if err != nil {
+				fmt.Println(err)
+				exit(1)
+			}
 		}
 
-		stream, err := cli.Read(ctx, req)
+		filters, err := parseMatchFilters(table, dumpMatch)
 		if err != nil {
-			log.Fatalf("Got error, %v \n", err.Error())
+			fmt.Println(err)
+			exit(1)
 		}
-
-		for {
-			rsp, err := stream.Recv()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatalf("Got error: %v", err)
+		if dumpOffset < 0 || dumpLimit < 0 {
+			fmt.Println("The --offset and --limit must not be negative")
+			exit(1)
+		}
+		var action actionInfo
+		if dumpAction != "" {
+			var ok bool
+			action, ok = searchAction(table, dumpAction)
+			if !ok {
+				fmt.Printf("Can not found action %s in table %s\n", dumpAction, table.Name)
+				exit(1)
 			}
-			if len(rsp.GetEntities()) == 0 {
-				fmt.Printf("The flows in %s is null\n", tableName)
+		}
+
+		if dumpOutput != "text" && dumpOutput != "json" {
+			fmt.Printf("Unsupported output %s, expect text or json\n", dumpOutput)
+			exit(1)
+		}
+		if dumpWatch > 0 {
+			if dumpCount {
+				fmt.Println("The --count can not be used with --watch")
+				exit(1)
 			}
-			for _, v := range rsp.Entities {
-				tbl := v.GetTableEntry()
-				if tbl.GetKey() != nil {
-					for _, f := range tbl.Key.Fields {
-						fmt.Printf("Match field ID: %d\n", f.FieldId)
-						switch strings.Split(reflect.TypeOf(f.GetMatchType()).String(), ".")[1] {
-						case "KeyField_Exact_":
-							m := f.GetExact()
-							fmt.Printf("Match field value: %x\n", m.Value)
-						case "KeyField_Ternary_":
-							t := f.GetTernary()
-							fmt.Printf("Ternary field value: %x, mask: %x\n", t.Value, t.Mask)
-						case "KeyField_Lpm":
-							l := f.GetLpm()
-							fmt.Printf("Lpm field value: %x, prefixLen: %d\n", l.Value, l.PrefixLen)
-						case "KeyField_Range_":
-							r := f.GetRange()
-							fmt.Printf("Range field high value: %x, low value: %x\n", r.High, r.Low)
-						}
-					}
-				} else {
-					fmt.Printf("Table default action:\n")
-				}
-				printNameById(tbl.Data.ActionId)
-				if tbl.Data.Fields != nil {
-					for _, d := range tbl.Data.Fields {
-						fmt.Printf("Action parameter field ID: %d\n", d.FieldId)
-						printNameById(d.FieldId)
-						fmt.Printf("Action parameter value: %x\n", d.GetStream())
+			watchTable(cli, ctx, table, key, filters, action)
+			return
+		}
+		if dumpOutput != "text" {
+			fmt.Println("The --output json is only supported with --watch")
+			exit(1)
+		}
+
+		entries := readDumpEntries(cli, ctx, table, key, filters, action)
+		if dumpCount {
+			fmt.Println(len(entries))
+			return
+		}
+		if len(entries) == 0 {
+			fmt.Printf("The flows in %s is null\n", tableName)
+			return
+		}
+
+		offset := dumpOffset
+		if offset > len(entries) {
+			offset = len(entries)
+		}
+		entries = entries[offset:]
+		if dumpLimit > 0 && dumpLimit < len(entries) {
+			entries = entries[:dumpLimit]
+		}
+		for _, tbl := range entries {
+			printTableEntry(table, tbl)
+		}
+	},
+}
+
+// readDumpEntries reads the flows of the table with the given key, and keeps
+// the ones matching the filters and the action when it is set.
+func readDumpEntries(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table, key *p4.TableKey, filters []keyFilter, action actionInfo) []*p4.TableEntry {
+	entries, err := tryReadDumpEntries(cli, ctx, table, key, filters, action)
+	if err != nil {
+		fatalf("Got error: %v", err)
+	}
+	return entries
+}
+
+// tryReadDumpEntries is readDumpEntries for dump --watch, which stops on a
+// cancelled read instead of failing.
+func tryReadDumpEntries(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table, key *p4.TableKey, filters []keyFilter, action actionInfo) ([]*p4.TableEntry, error) {
+	entities, err := tryReadEntities(cli, ctx, &p4.Entity{
+		Entity: &p4.Entity_TableEntry{
+			TableEntry: &p4.TableEntry{
+				TableId:       table.ID,
+				Key:           key,
+				TableReadFlag: &p4.TableReadFlag{FromHw: dumpFromHw},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*p4.TableEntry
+	for _, v := range entities {
+		tbl := v.GetTableEntry()
+		if tbl == nil || !matchFilters(filters, tbl) {
+			continue
+		}
+		if action.Name != "" && tbl.GetData().GetActionId() != action.ID {
+			continue
+		}
+		entries = append(entries, tbl)
+	}
+	return entries, nil
+}
+
+func printTableEntry(table *util.Table, tbl *p4.TableEntry) {
+	if tbl.GetKey() != nil {
+		for _, f := range tbl.Key.Fields {
+			fmt.Printf("Match field ID: %d\n", f.FieldId)
+			switch strings.Split(reflect.TypeOf(f.GetMatchType()).String(), ".")[1] {
+			case "KeyField_Exact_":
+				m := f.GetExact()
+				fmt.Printf("Match field value: %x\n", m.Value)
+				if k, ok := searchFieldById(keyFields(table), f.FieldId); ok {
+					if port, ok := formatPortValue(k.Name, m.Value); ok {
+						fmt.Printf("Match field port: %s=%s\n", k.Name, port)
 					}
 				}
-				fmt.Printf("------------------\n")
+			case "KeyField_Ternary_":
+				t := f.GetTernary()
+				fmt.Printf("Ternary field value: %x, mask: %x\n", t.Value, t.Mask)
+			case "KeyField_Lpm":
+				l := f.GetLpm()
+				fmt.Printf("Lpm field value: %x, prefixLen: %d\n", l.Value, l.PrefixLen)
+			case "KeyField_Range_":
+				r := f.GetRange()
+				fmt.Printf("Range field high value: %x, low value: %x\n", r.High, r.Low)
 			}
 		}
-	},
+	} else {
+		fmt.Printf("Table default action:\n")
+	}
+	printNameById(tbl.Data.ActionId)
+	if tbl.Data.Fields != nil {
+		for _, d := range tbl.Data.Fields {
+			fmt.Printf("Action parameter field ID: %d\n", d.FieldId)
+			printNameById(d.FieldId)
+			fmt.Printf("Action parameter value: %x\n", d.GetStream())
+			name := dataFieldName(table, tbl.Data.ActionId, d.FieldId)
+			if port, ok := formatPortValue(name, d.GetStream()); ok {
+				fmt.Printf("Action parameter port: %s=%s\n", name, port)
+			}
+		}
+	}
+	fmt.Printf("------------------\n")
+}
+
+// keyFilter is a --match condition on one key field.
+type keyFilter struct {
+	field     fieldInfo
+	value     *big.Int
+	prefixLen int
+}
+
+func parseMatchFilters(table *util.Table, matches []string) ([]keyFilter, error) {
+	keys := keyFields(table)
+	filters := make([]keyFilter, 0, len(matches))
+	for _, m := range matches {
+		name, value, err := parseAssignment(m)
+		if err != nil {
+			return nil, err
+		}
+		field, ok := searchField(keys, name)
+		if !ok {
+			return nil, fmt.Errorf("can not found key field %s in table %s", name, table.Name)
+		}
+		filter := keyFilter{field: field, prefixLen: -1}
+		// Front-panel names such as 3/0 are not prefixes.
+		if value, err = translatePortValue(field, value); err != nil {
+			return nil, err
+		}
+		if i := strings.LastIndex(value, "/"); i >= 0 {
+			n, err := strconv.Atoi(value[i+1:])
+			if err != nil || n < 0 || (field.bitWidth() > 0 && n > field.bitWidth()) {
+				return nil, fmt.Errorf("invalid prefix length in %q", value)
+			}
+			filter.prefixLen, value = n, value[:i]
+		}
+		raw, err := encodeValue(value, field.bitWidth())
+		if err != nil {
+			return nil, err
+		}
+		filter.value = decodeValue(raw)
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// matchFilters reports whether the entry satisfies every filter. A key field
+// missing from the entry is a wildcard and matches anything.
+func matchFilters(filters []keyFilter, tbl *p4.TableEntry) bool {
+	for _, filter := range filters {
+		for _, f := range tbl.GetKey().GetFields() {
+			if f.FieldId == filter.field.ID && !filter.match(f) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (filter keyFilter) match(f *p4.KeyField) bool {
+	switch f.GetMatchType().(type) {
+	case *p4.KeyField_Exact_:
+		return filter.value.Cmp(decodeValue(f.GetExact().GetValue())) == 0
+	case *p4.KeyField_Lpm:
+		lpm := f.GetLpm()
+		if filter.prefixLen >= 0 && filter.prefixLen < int(lpm.GetPrefixLen()) {
+			return false
+		}
+		width := filter.field.bitWidth()
+		if width == 0 {
+			width = len(lpm.GetValue()) * 8
+		}
+		mask := prefixMask(width, int(lpm.GetPrefixLen()))
+		return new(big.Int).And(filter.value, mask).Cmp(new(big.Int).And(decodeValue(lpm.GetValue()), mask)) == 0
+	case *p4.KeyField_Ternary_:
+		mask := decodeValue(f.GetTernary().GetMask())
+		return new(big.Int).And(filter.value, mask).Cmp(new(big.Int).And(decodeValue(f.GetTernary().GetValue()), mask)) == 0
+	case *p4.KeyField_Range_:
+		r := f.GetRange()
+		return filter.value.Cmp(decodeValue(r.GetLow())) >= 0 && filter.value.Cmp(decodeValue(r.GetHigh())) <= 0
+	}
+	return false
+}
+
+// prefixMask returns the mask of the leading prefixLen bits of a width bits
+// value. A prefix length outside 0 to width is clamped to it.
+func prefixMask(width, prefixLen int) *big.Int {
+	if prefixLen < 0 {
+		prefixLen = 0
+	}
+	if prefixLen > width {
+		prefixLen = width
+	}
+	all := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(width)), big.NewInt(1))
+	host := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(width-prefixLen)), big.NewInt(1))
+	return all.Xor(all, host)
 }
 
 func init() {
 	rootCmd.AddCommand(dumpCmd)
+	dumpCmd.Flags().BoolVar(&dumpFromHw, "from-hw", false, "Read the flows from hardware instead of software shadow")
+	dumpCmd.Flags().StringArrayVarP(&dumpMatch, "match", "m", nil, "Only show the flows matching KEY=VALUE, can be repeated")
+	dumpCmd.Flags().StringVar(&dumpAction, "action", "", "Only show the flows with the action")
+	dumpCmd.Flags().IntVar(&dumpLimit, "limit", 0, "Show at most the number of flows")
+	dumpCmd.Flags().IntVar(&dumpOffset, "offset", 0, "Skip the number of flows before showing")
+	dumpCmd.Flags().BoolVarP(&dumpCount, "count", "c", false, "Only show the number of flows")
+	dumpCmd.Flags().DurationVarP(&dumpWatch, "watch", "w", 0, "Read the flows on the interval and show the changes, e.g. 2s")
+	dumpCmd.Flags().StringVarP(&dumpOutput, "output", "o", "text", "The output format of --watch, text or json")
+	registerFlagCompletion(dumpCmd, "action", completeActionFlag)
+	registerFlagCompletion(dumpCmd, "output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp
+	})
 
 	// Here you will define your flags and configuration settings.
 
@@ -0,0 +1,246 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The counters of a flow whose deltas are shown by dump --watch.
+var counterDataFields = []string{"$COUNTER_SPEC_BYTES", "$COUNTER_SPEC_PKTS"}
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+	clearScreen = "\033[H\033[2J"
+)
+
+// watchedFlow is a flow of the table seen at a read.
+type watchedFlow struct {
+	key      string
+	data     string
+	counters map[string]*big.Int
+}
+
+// watchEvent is a change of a flow between two reads, written as a line of
+// JSON with -o json.
+type watchEvent struct {
+	Time     string              `json:"time"`
+	Event    string              `json:"event"`
+	Table    string              `json:"table"`
+	Key      string              `json:"key"`
+	Data     string              `json:"data,omitempty"`
+	OldData  string              `json:"old_data,omitempty"`
+	Counters map[string]*big.Int `json:"counters,omitempty"`
+}
+
+// watchTable reads the table on the dump --watch interval and shows the
+// changes until the command is interrupted.
+func watchTable(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table, key *p4.TableKey, filters []keyFilter, action actionInfo) {
+	var counters []fieldInfo
+	for _, name := range counterDataFields {
+		if f, ok := searchField(dataFields(table), name); ok {
+			counters = append(counters, f)
+		}
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	ctx, stop := interruptContext(ctx)
+	defer stop()
+
+	var prev map[string]watchedFlow
+	for {
+		if len(counters) != 0 && !dumpFromHw {
+			if err := syncTable(cli, ctx, table.ID, "SyncCounters"); err != nil && !isCanceled(ctx, err) {
+				fmt.Fprintf(os.Stderr, "Got error when syncing counters: %v\n", err)
+			}
+		}
+		entries, err := tryReadDumpEntries(cli, ctx, table, key, filters, action)
+		if err != nil && isCanceled(ctx, err) {
+			return
+		}
+		if err != nil {
+			fatalf("Got error: %v", err)
+		}
+		cur := map[string]watchedFlow{}
+		for _, tbl := range entries {
+			flow := watchedFlow{
+				key:      formatTableKey(table, tbl.GetKey()),
+				data:     formatEntryData(table, tbl.GetData()),
+				counters: map[string]*big.Int{},
+			}
+			for _, c := range counters {
+				if values := collectDataValues(tbl.GetData().GetFields(), c.ID); len(values) != 0 {
+					flow.counters[counterName(c.Name)] = values[0]
+				}
+			}
+			cur[flow.key] = flow
+		}
+
+		events := diffWatchedFlows(table, prev, cur, time.Now())
+		if dumpOutput == "json" {
+			for _, e := range events {
+				encoder.Encode(e)
+			}
+		} else {
+			printWatchedFlows(table, cur, events)
+		}
+		prev = cur
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(dumpWatch):
+		}
+	}
+}
+
+// diffWatchedFlows lists the flows added, removed, modified and the ones
+// whose counters changed, ordered by key. A nil prev is the first read, which
+// is the baseline of the following reads and gives no events.
+func diffWatchedFlows(table *util.Table, prev, cur map[string]watchedFlow, now time.Time) []watchEvent {
+	if prev == nil {
+		return nil
+	}
+	stamp := now.Format(time.RFC3339)
+	var events []watchEvent
+	for k, flow := range cur {
+		old, ok := prev[k]
+		event := watchEvent{Time: stamp, Table: table.Name, Key: k, Data: flow.data}
+		switch {
+		case !ok:
+			event.Event = "added"
+		case old.data != flow.data:
+			event.Event, event.OldData = "modified", old.data
+			event.Counters = counterDeltas(old, flow)
+		default:
+			event.Counters = counterDeltas(old, flow)
+			if len(event.Counters) == 0 {
+				continue
+			}
+			event.Event = "counters"
+		}
+		events = append(events, event)
+	}
+	for k, old := range prev {
+		if _, ok := cur[k]; !ok {
+			events = append(events, watchEvent{Time: stamp, Event: "removed", Table: table.Name, Key: k, Data: old.data})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Key < events[j].Key
+	})
+	return events
+}
+
+// counterDeltas returns the non-zero changes of counters between two reads.
+func counterDeltas(old, cur watchedFlow) map[string]*big.Int {
+	deltas := map[string]*big.Int{}
+	for name, v := range cur.counters {
+		last, ok := old.counters[name]
+		if !ok {
+			continue
+		}
+		if d := new(big.Int).Sub(v, last); d.Sign() != 0 {
+			deltas[name] = d
+		}
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+	return deltas
+}
+
+// printWatchedFlows refreshes the terminal with the flows of the table,
+// highlighting the changes since the previous read.
+func printWatchedFlows(table *util.Table, cur map[string]watchedFlow, events []watchEvent) {
+	changes := map[string]watchEvent{}
+	count := map[string]int{}
+	for _, e := range events {
+		changes[e.Key] = e
+		count[e.Event]++
+	}
+
+	fmt.Print(clearScreen)
+	fmt.Printf("Every %v: dump %s    %s\n", dumpWatch, table.Name, time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Printf("%d flows, %d added, %d removed, %d modified\n\n",
+		len(cur), count["added"], count["removed"], count["modified"])
+
+	keys := make([]string, 0, len(cur))
+	for k := range cur {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		flow := cur[k]
+		e := changes[k]
+		marker, color := " ", ""
+		switch e.Event {
+		case "added":
+			marker, color = "+", colorGreen
+		case "modified":
+			marker, color = "~", colorYellow
+		case "counters":
+			color = colorCyan
+		}
+		line := fmt.Sprintf("%s %s -> %s", marker, k, flow.data)
+		if len(flow.counters) != 0 {
+			line += " " + formatCounters(flow.counters, e.Counters)
+		}
+		if color != "" {
+			line = color + line + colorReset
+		}
+		fmt.Println(line)
+	}
+	for _, e := range events {
+		if e.Event == "removed" {
+			fmt.Printf("%s- %s -> %s%s\n", colorRed, e.Key, e.Data, colorReset)
+		}
+	}
+}
+
+// formatCounters renders the counters of a flow with their deltas.
+func formatCounters(counters, deltas map[string]*big.Int) string {
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		part := fmt.Sprintf("%s=%s", name, counters[name])
+		if d, ok := deltas[name]; ok {
+			part += fmt.Sprintf(" (%+d)", d)
+		}
+		parts = append(parts, part)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+func counterName(name string) string {
+	return strings.ToLower(strings.TrimPrefix(name, "$COUNTER_SPEC_"))
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestDiffWatchedFlows(t *testing.T) {
+	table := testTable(t, `{"name": "SwitchIngress.acl"}`)
+	flow := func(data string, pkts int64) watchedFlow {
+		return watchedFlow{data: data, counters: map[string]*big.Int{"$COUNTER_SPEC_PKTS": big.NewInt(pkts)}}
+	}
+	prev := map[string]watchedFlow{
+		"k=1": flow("drop", 10),
+		"k=2": flow("forward port=1", 5),
+		"k=3": flow("drop", 7),
+		"k=4": flow("drop", 0),
+	}
+	cur := map[string]watchedFlow{
+		"k=1": flow("drop", 10),
+		"k=2": flow("forward port=2", 6),
+		"k=3": flow("drop", 9),
+		"k=5": flow("drop", 0),
+	}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := diffWatchedFlows(table, prev, cur, now)
+
+	want := []string{
+		"k=2 modified forward port=2 old=forward port=1 map[$COUNTER_SPEC_PKTS:1]",
+		"k=3 counters drop old= map[$COUNTER_SPEC_PKTS:2]",
+		"k=4 removed drop old= map[]",
+		"k=5 added drop old= map[]",
+	}
+	if len(events) != len(want) {
+		t.Fatalf("diffWatchedFlows gives %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, e := range events {
+		got := fmt.Sprintf("%s %s %s old=%s %v", e.Key, e.Event, e.Data, e.OldData, e.Counters)
+		if got != want[i] {
+			t.Errorf("event %d = %q, want %q", i, got, want[i])
+		}
+		if e.Table != table.Name || e.Time != "2026-01-02T03:04:05Z" {
+			t.Errorf("event %d of table %q at %q", i, e.Table, e.Time)
+		}
+	}
+
+	// The first read is the baseline, only the later reads give events.
+	if events := diffWatchedFlows(table, nil, cur, now); len(events) != 0 {
+		t.Errorf("the first read gives %d events, want none: %+v", len(events), events)
+	}
+	events = diffWatchedFlows(table, map[string]watchedFlow{}, cur, now)
+	if len(events) != len(cur) || events[0].Event != "added" {
+		t.Errorf("a read after an empty table gives %+v, want every flow added", events)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/P4Networking/proto/go/p4"
+	"math/big"
+	"testing"
+)
+
+func TestPrefixMask(t *testing.T) {
+	tests := []struct {
+		width, prefixLen int
+		want             int64
+	}{
+		{8, 0, 0x00},
+		{8, 4, 0xf0},
+		{8, 8, 0xff},
+		{8, 12, 0xff},
+		{8, -1, 0x00},
+		{32, 24, 0xffffff00},
+	}
+	for _, tt := range tests {
+		if got := prefixMask(tt.width, tt.prefixLen); got.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("prefixMask(%d, %d) = %#x, want %#x", tt.width, tt.prefixLen, got, tt.want)
+		}
+	}
+}
+
+func TestKeyFilterMatch(t *testing.T) {
+	exact := &p4.KeyField{FieldId: 1, MatchType: &p4.KeyField_Exact_{Exact: &p4.KeyField_Exact{Value: []byte{0, 10}}}}
+	lpm := &p4.KeyField{FieldId: 1, MatchType: &p4.KeyField_Lpm{Lpm: &p4.KeyField_LPM{Value: []byte{10, 1, 0, 0}, PrefixLen: 16}}}
+	ternary := &p4.KeyField{FieldId: 1, MatchType: &p4.KeyField_Ternary_{Ternary: &p4.KeyField_Ternary{Value: []byte{0x10}, Mask: []byte{0xf0}}}}
+	ranged := &p4.KeyField{FieldId: 1, MatchType: &p4.KeyField_Range_{Range: &p4.KeyField_Range{Low: []byte{0, 10}, High: []byte{0, 20}}}}
+	tests := []struct {
+		name      string
+		field     *p4.KeyField
+		width     int
+		value     int64
+		prefixLen int
+		want      bool
+	}{
+		{"exact equal", exact, 16, 10, -1, true},
+		{"exact differ", exact, 16, 11, -1, false},
+		{"lpm covered address", lpm, 32, 0x0a010203, -1, true},
+		{"lpm other address", lpm, 32, 0x0a020000, -1, false},
+		{"lpm longer filter prefix", lpm, 32, 0x0a010100, 24, true},
+		{"lpm shorter filter prefix", lpm, 32, 0x0a000000, 8, false},
+		{"lpm same filter prefix", lpm, 32, 0x0a010000, 16, true},
+		{"ternary masked bits", ternary, 8, 0x1f, -1, true},
+		{"ternary differ", ternary, 8, 0x20, -1, false},
+		{"range inside", ranged, 16, 15, -1, true},
+		{"range bound", ranged, 16, 20, -1, true},
+		{"range outside", ranged, 16, 21, -1, false},
+	}
+	for _, tt := range tests {
+		filter := keyFilter{field: fieldInfo{ID: 1, Width: tt.width}, value: big.NewInt(tt.value), prefixLen: tt.prefixLen}
+		if got := filter.match(tt.field); got != tt.want {
+			t.Errorf("%s: match = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseMatchFilters(t *testing.T) {
+	table := testTable(t, testLpmTable)
+	tests := []struct {
+		match     string
+		value     int64
+		prefixLen int
+		wantErr   bool
+	}{
+		{"dst_addr=10.0.0.0/8", 0x0a000000, 8, false},
+		{"dst_addr=10.0.0.1", 0x0a000001, -1, false},
+		{"vrf=3", 3, -1, false},
+		{"dst_addr=10.0.0.0/40", 0, 0, true},
+		{"nexthop=1", 0, 0, true},
+		{"vrf", 0, 0, true},
+	}
+	for _, tt := range tests {
+		filters, err := parseMatchFilters(table, []string{tt.match})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMatchFilters(%q) error = %v, want error %t", tt.match, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if f := filters[0]; f.value.Cmp(big.NewInt(tt.value)) != 0 || f.prefixLen != tt.prefixLen {
+			t.Errorf("parseMatchFilters(%q) = %#x/%d, want %#x/%d", tt.match, f.value, f.prefixLen, tt.value, tt.prefixLen)
+		}
+	}
+}
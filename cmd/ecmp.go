@@ -0,0 +1,167 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+)
+
+// ecmpCmd represents the ecmp command
+var ecmpCmd = &cobra.Command{
+	Use:   "ecmp",
+	Short: "Show ECMP and LAG resolution of selector tables",
+	Long:  `Show how flows of tables using action selectors resolve to next hops`,
+}
+
+var ecmpShowCmd = &cobra.Command{
+	Use:   "show TABLE-NAME [KEY=VALUE...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Show the next hops of flows",
+	Long: `Follow the flows of a match table through their selector group to the
+action profile members, and print the action data and status of each member
+as a tree.
+
+  bfcli ecmp show SwitchIngress.ipv4_ecmp
+  bfcli ecmp show SwitchIngress.ipv4_ecmp ipv4_dst=10.0.0.0/8`,
+	ValidArgsFunction: completeTableArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTable(args[0])
+		if table == nil {
+			fmt.Printf("Can not found table with name: %s\n", args[0])
+			exit(1)
+		}
+		selector, profile := searchProfileTables(table)
+		if profile == nil {
+			fmt.Printf("The table %s does not use an action profile\n", table.Name)
+			exit(1)
+		}
+
+		var key *p4.TableKey
+		if len(args) > 1 {
+			var err error
+			key, err = buildTableKey(table, args[1:])
+			if err != nil {
+				fmt.Println(err)
+				exit(1)
+			}
+		}
+
+		members := map[uint32]profileMember{}
+		for _, m := range readProfileMembers(cli, ctx, profile) {
+			members[m.id] = m
+		}
+		groups := map[uint32]selectorGroup{}
+		if selector != nil {
+			selected, err := readSelectorGroups(cli, ctx, selector, nil)
+			if err != nil {
+				fmt.Printf("Got error when reading groups of %s: %v\n", selector.Name, err)
+				exit(1)
+			}
+			for _, g := range selected {
+				groups[g.id] = g
+			}
+		}
+
+		data := dataFields(table)
+		memberField, _ := searchField(data, ACTION_MEMBER_ID)
+		groupField, _ := searchField(data, SELECTOR_GROUP_ID)
+
+		flows := readEntities(cli, ctx, tableEntryEntity(table, key, nil))
+		if len(flows) == 0 {
+			fmt.Printf("The flows in %s is null\n", table.Name)
+			return
+		}
+		for _, e := range flows {
+			tbl := e.GetTableEntry()
+			if tbl == nil {
+				continue
+			}
+			fmt.Println(formatTableKey(table, tbl.GetKey()))
+			fields := tbl.GetData().GetFields()
+			if v := collectDataValues(fields, groupField.ID); selector != nil && len(v) != 0 {
+				id := uint32(v[0].Uint64())
+				group, ok := groups[id]
+				if !ok {
+					fmt.Printf("└── group %d (missing in %s)\n", id, selector.Name)
+					continue
+				}
+				fmt.Printf("└── group %d (%s)\n", id, selector.Name)
+				for i, m := range group.members {
+					branch := "├──"
+					if i == len(group.members)-1 {
+						branch = "└──"
+					}
+					fmt.Printf("    %s %s\n", branch, formatMember(profile, members, m, memberStatus(group, i)))
+				}
+				continue
+			}
+			if v := collectDataValues(fields, memberField.ID); len(v) != 0 {
+				fmt.Printf("└── %s\n", formatMember(profile, members, uint32(v[0].Uint64()), "active"))
+				continue
+			}
+			fmt.Printf("└── %s\n", formatEntryData(table, tbl.GetData()))
+		}
+	},
+}
+
+func formatMember(profile *util.Table, members map[uint32]profileMember, id uint32, status string) string {
+	m, ok := members[id]
+	if !ok {
+		return fmt.Sprintf("member %d [%s] (missing in %s)", id, status, profile.Name)
+	}
+	return fmt.Sprintf("member %d [%s] %s", id, status, formatEntryData(profile, m.data))
+}
+
+// searchProfileTables finds the selector and the action profile a match
+// table depends on. The action profile is also looked up through the
+// selector, since the selector itself depends on it.
+func searchProfileTables(table *util.Table) (*util.Table, *util.Table) {
+	var selector, profile *util.Table
+	for _, id := range table.DependsOn {
+		t := findTableById(uint32(id))
+		if t == nil {
+			continue
+		}
+		switch t.TableType {
+		case SELECTOR_TABLE_TYPE:
+			selector = t
+		case PROFILE_TABLE_TYPE:
+			profile = t
+		}
+	}
+	if profile == nil && selector != nil {
+		for _, id := range selector.DependsOn {
+			if t := findTableById(uint32(id)); t != nil && t.TableType == PROFILE_TABLE_TYPE {
+				profile = t
+			}
+		}
+	}
+	return selector, profile
+}
+
+func init() {
+	rootCmd.AddCommand(ecmpCmd)
+	ecmpCmd.AddCommand(ecmpShowCmd)
+}
@@ -0,0 +1,236 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+var (
+	exportFormat string
+	exportOutput string
+	exportHex    bool
+	exportFromHw bool
+)
+
+// exportColumn is a CSV column taking the value of a key or data field.
+type exportColumn struct {
+	header string
+	id     uint32
+	isKey  bool
+}
+
+// The separators of the export formats.
+var exportFormats = map[string]rune{
+	"csv": ',',
+	"tsv": '\t',
+}
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export TABLE-NAME",
+	Args:  cobra.ExactArgs(1),
+	Short: "Export the flows of specify table to CSV",
+	Long: `Export the flows of specify table with one row per flow. The headers are the
+key field names, the action and the data field names from the schema. Counters,
+TTL and hit state are left out. Key fields are written like set-flow takes them:
+value&&&mask for ternary, value/prefix-len for LPM and low..high for range
+fields, so the file can be read back by import. Values are decimal, or dotted
+quads for IPv4 addresses, unless --hex is given.
+
+  bfcli export SwitchIngress.ipv4_lpm --format csv -o routes.csv`,
+	ValidArgsFunction: completeTableName,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTable(args[0])
+		if table == nil {
+			fmt.Printf("Can not found table with name: %s\n", args[0])
+			exit(1)
+		}
+		comma, ok := exportFormats[exportFormat]
+		if !ok {
+			fmt.Printf("Unsupported format %s, expect csv or tsv\n", exportFormat)
+			exit(1)
+		}
+
+		entries := readEntities(cli, ctx, &p4.Entity{
+			Entity: &p4.Entity_TableEntry{
+				TableEntry: &p4.TableEntry{
+					TableId:       table.ID,
+					TableReadFlag: &p4.TableReadFlag{FromHw: exportFromHw},
+				},
+			},
+		})
+
+		// The file is created once the flows are read, so a failed read does
+		// not leave an empty file behind.
+		var out io.Writer = os.Stdout
+		if exportOutput != "" {
+			f, err := os.Create(exportOutput)
+			if err != nil {
+				fmt.Println(err)
+				exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		columns := exportColumns(table)
+		w := csv.NewWriter(out)
+		w.Comma = comma
+		header := []string{}
+		for _, c := range columns {
+			header = append(header, c.header)
+		}
+		w.Write(header)
+		rows := 0
+		for _, e := range entries {
+			tbl := e.GetTableEntry()
+			if tbl == nil {
+				continue
+			}
+			w.Write(exportRow(table, columns, tbl))
+			rows++
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			fatalf("Got error when writing %s: %v", exportOutput, err)
+		}
+		if exportOutput != "" {
+			fmt.Printf("Exported %d flows of %s to %s\n", rows, table.Name, exportOutput)
+		}
+	},
+}
+
+// exportColumns lists the columns of a table: the key fields, the action,
+// then every action parameter and data field once, leaving out the volatile
+// data fields.
+func exportColumns(table *util.Table) []exportColumn {
+	var columns []exportColumn
+	for _, k := range keyFields(table) {
+		columns = append(columns, exportColumn{header: k.Name, id: k.ID, isKey: true})
+	}
+
+	actions := actionSpecs(table)
+	if len(actions) != 0 {
+		columns = append(columns, exportColumn{header: ACTION_COLUMN})
+	}
+	seen := map[string]bool{}
+	for _, a := range actions {
+		for _, p := range a.Params {
+			if !seen[p.Name] {
+				seen[p.Name] = true
+				columns = append(columns, exportColumn{header: p.Name})
+			}
+		}
+	}
+	for _, d := range dataFields(table) {
+		if !seen[d.Name] && !isVolatileField(d.Name) {
+			seen[d.Name] = true
+			columns = append(columns, exportColumn{header: d.Name})
+		}
+	}
+	return columns
+}
+
+// exportRow renders an entry along the columns, leaving the cells of fields
+// absent from the entry empty.
+func exportRow(table *util.Table, columns []exportColumn, tbl *p4.TableEntry) []string {
+	data := map[string]string{}
+	if a, ok := searchActionById(table, tbl.GetData().GetActionId()); ok {
+		data[ACTION_COLUMN] = a.Name
+	}
+	for _, d := range tbl.GetData().GetFields() {
+		name := dataFieldName(table, tbl.GetData().GetActionId(), d.FieldId)
+		if s, ok := d.GetValue().(*p4.DataField_Stream); ok {
+			data[name] = exportValue(name, s.Stream)
+		} else {
+			data[name] = formatDataField(d)
+		}
+	}
+
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		if !c.isKey {
+			row[i] = data[c.header]
+			continue
+		}
+		for _, f := range tbl.GetKey().GetFields() {
+			if f.FieldId == c.id {
+				row[i] = exportKeyValue(c.header, f)
+			}
+		}
+	}
+	return row
+}
+
+// exportKeyValue renders a key field in the syntax buildTableKey takes.
+func exportKeyValue(name string, f *p4.KeyField) string {
+	switch m := f.GetMatchType().(type) {
+	case *p4.KeyField_Exact_:
+		return exportValue(name, m.Exact.GetValue())
+	case *p4.KeyField_Ternary_:
+		return exportValue(name, m.Ternary.GetValue()) + "&&&" + exportValue(name, m.Ternary.GetMask())
+	case *p4.KeyField_Lpm:
+		return fmt.Sprintf("%s/%d", exportValue(name, m.Lpm.GetValue()), m.Lpm.GetPrefixLen())
+	case *p4.KeyField_Range_:
+		return exportValue(name, m.Range.GetLow()) + ".." + exportValue(name, m.Range.GetHigh())
+	}
+	return ""
+}
+
+// exportValue renders a value as decimal, or as a dotted quad when the field
+// holds an IPv4 address, judged by its width and name.
+func exportValue(name string, b []byte) string {
+	if exportHex {
+		return fmt.Sprintf("0x%x", b)
+	}
+	if len(b) == net.IPv4len && isAddressField(name) {
+		return net.IP(b).String()
+	}
+	return decodeValue(b).String()
+}
+
+// isAddressField reports whether the last dotted component of a field name
+// such as "hdr.ipv4.dst_addr" or "nexthop_ip" names an address.
+func isAddressField(name string) bool {
+	name = strings.ToLower(name[strings.LastIndex(name, ".")+1:])
+	return strings.Contains(name, "addr") || strings.Contains(name, "ip")
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "csv", "The output format, csv or tsv")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write to the file instead of standard output")
+	exportCmd.Flags().BoolVar(&exportHex, "hex", false, "Show the values in hex")
+	exportCmd.Flags().BoolVar(&exportFromHw, "from-hw", false, "Read the flows from hardware instead of software shadow")
+	registerFlagCompletion(exportCmd, "format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"csv", "tsv"}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
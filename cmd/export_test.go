@@ -0,0 +1,128 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/P4Networking/proto/go/p4"
+	"reflect"
+	"testing"
+)
+
+func TestExportRow(t *testing.T) {
+	table := testTable(t, testLpmTable)
+	columns := exportColumns(table)
+	headers := make([]string, 0, len(columns))
+	for _, c := range columns {
+		headers = append(headers, c.header)
+	}
+	wantHeaders := []string{"hdr.ipv4.dst_addr", "vrf", "action", "port", "dmac"}
+	if !reflect.DeepEqual(headers, wantHeaders) {
+		t.Fatalf("exportColumns = %q, want %q", headers, wantHeaders)
+	}
+
+	nexthop := &p4.TableEntry{
+		Key: &p4.TableKey{Fields: []*p4.KeyField{
+			{FieldId: 1, MatchType: &p4.KeyField_Lpm{Lpm: &p4.KeyField_LPM{Value: []byte{10, 0, 0, 0}, PrefixLen: 8}}},
+		}},
+		Data: &p4.TableData{ActionId: 10, Fields: []*p4.DataField{
+			{FieldId: 1, Value: &p4.DataField_Stream{Stream: []byte{0, 5}}},
+			{FieldId: 2, Value: &p4.DataField_Stream{Stream: []byte{0, 0, 0, 0, 0, 0xff}}},
+			{FieldId: 65553, Value: &p4.DataField_Stream{Stream: []byte{1, 0}}},
+		}},
+	}
+	drop := &p4.TableEntry{
+		Key: &p4.TableKey{Fields: []*p4.KeyField{
+			{FieldId: 1, MatchType: &p4.KeyField_Lpm{Lpm: &p4.KeyField_LPM{Value: []byte{10, 1, 0, 0}, PrefixLen: 16}}},
+			{FieldId: 2, MatchType: &p4.KeyField_Exact_{Exact: &p4.KeyField_Exact{Value: []byte{3}}}},
+		}},
+		Data: &p4.TableData{ActionId: 11},
+	}
+	defer func() {
+		exportHex = false
+	}()
+	tests := []struct {
+		entry *p4.TableEntry
+		hex   bool
+		want  []string
+	}{
+		{nexthop, false, []string{"10.0.0.0/8", "", "SwitchIngress.set_nexthop", "5", "255"}},
+		{nexthop, true, []string{"0x0a000000/8", "", "SwitchIngress.set_nexthop", "0x0005", "0x0000000000ff"}},
+		{drop, false, []string{"10.1.0.0/16", "3", "SwitchIngress.drop", "", ""}},
+	}
+	for _, tt := range tests {
+		exportHex = tt.hex
+		if got := exportRow(table, columns, tt.entry); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("exportRow with --hex=%t = %q, want %q", tt.hex, got, tt.want)
+		}
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	table := testTable(t, `{
+		"name": "SwitchIngress.acl", "id": 2, "table_type": "MatchAction_Direct", "size": 256,
+		"key": [
+			{"id": 1, "name": "hdr.ipv4.src_addr", "match_type": "Ternary", "mandatory": true, "type": {"type": "bytes", "width": 32}},
+			{"id": 2, "name": "hdr.tcp.dst_port", "match_type": "Range", "mandatory": true, "type": {"type": "bytes", "width": 16}},
+			{"id": 3, "name": "$MATCH_PRIORITY", "match_type": "Exact", "mandatory": true, "type": {"type": "uint32", "width": 32}}
+		],
+		"action_specs": [
+			{"id": 20, "name": "SwitchIngress.redirect", "data": [
+				{"id": 1, "name": "nexthop_ip", "mandatory": true, "type": {"type": "bytes", "width": 32}}
+			]}
+		],
+		"data": [
+			{"mandatory": false, "singleton": {"id": 65553, "name": "$COUNTER_SPEC_PKTS", "type": {"type": "uint64", "width": 64}}}
+		]
+	}`)
+	entry := &p4.TableEntry{
+		Key: &p4.TableKey{Fields: []*p4.KeyField{
+			{FieldId: 1, MatchType: &p4.KeyField_Ternary_{Ternary: &p4.KeyField_Ternary{Value: []byte{192, 168, 1, 0}, Mask: []byte{255, 255, 255, 0}}}},
+			{FieldId: 2, MatchType: &p4.KeyField_Range_{Range: &p4.KeyField_Range{Low: []byte{0, 80}, High: []byte{0x01, 0xbb}}}},
+			{FieldId: 3, MatchType: &p4.KeyField_Exact_{Exact: &p4.KeyField_Exact{Value: []byte{0, 0, 0, 10}}}},
+		}},
+		Data: &p4.TableData{ActionId: 20, Fields: []*p4.DataField{
+			{FieldId: 1, Value: &p4.DataField_Stream{Stream: []byte{10, 0, 0, 1}}},
+			{FieldId: 65553, Value: &p4.DataField_Stream{Stream: []byte{0, 0, 0, 0, 0, 0, 0, 7}}},
+		}},
+	}
+
+	columns := exportColumns(table)
+	headers := make([]string, 0, len(columns))
+	for _, c := range columns {
+		headers = append(headers, c.header)
+	}
+	row := exportRow(table, columns, entry)
+	want := []string{"192.168.1.0&&&255.255.255.0", "80..443", "10", "SwitchIngress.redirect", "10.0.0.1"}
+	if !reflect.DeepEqual(row, want) {
+		t.Fatalf("exportRow = %q, want %q", row, want)
+	}
+
+	imported, err := parseImportColumns(table, headers)
+	if err != nil {
+		t.Fatalf("parseImportColumns(%q): %v", headers, err)
+	}
+	update, err := importRow(table, imported, row, p4.Update_INSERT)
+	if err != nil {
+		t.Fatalf("importRow(%q): %v", row, err)
+	}
+	got := update.Entity.GetTableEntry()
+	if formatTableKey(table, got.GetKey()) != formatTableKey(table, entry.GetKey()) {
+		t.Errorf("imported key %s, want %s", formatTableKey(table, got.GetKey()), formatTableKey(table, entry.GetKey()))
+	}
+	if formatEntryData(table, got.GetData()) != formatEntryData(table, entry.GetData()) {
+		t.Errorf("imported data %s, want %s", formatEntryData(table, got.GetData()), formatEntryData(table, entry.GetData()))
+	}
+}
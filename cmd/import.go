@@ -0,0 +1,294 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The CSV column holding the action name of the flow.
+const ACTION_COLUMN = "action"
+
+var (
+	importCsv     string
+	importColumns []string
+	importAction  string
+	importBatch   int
+	importWorkers int
+	importModify  bool
+)
+
+// importColumn is how a CSV column is encoded into a flow.
+type importColumn struct {
+	name  string
+	isKey bool
+}
+
+// updateBatch is a batch of updates with the CSV rows they come from.
+type updateBatch struct {
+	first, last int
+	updates     []*p4.Update
+}
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import TABLE-NAME",
+	Args:  cobra.ExactArgs(1),
+	Short: "Import flows from a CSV file",
+	Long: `Import flows from a CSV file into specify table. Every row is a flow, whose
+columns are named by --columns or by the first row of the file. A column is
+either a key field, "action" for the action name, an action parameter or a
+table data field such as $METER_SPEC_CIR_KBPS. An empty column name skips the
+column and an empty cell leaves the field unset.
+Key values use the syntax of set-flow, e.g. 10.0.0.0/8 for LPM fields.
+
+The flows are written in batches with concurrent Write RPCs, and the progress
+is reported every second.
+
+  bfcli import SwitchIngress.ipv4_lpm --csv routes.csv --columns ipv4_dst,action,port
+  bfcli import SwitchIngress.ipv4_lpm --csv routes.csv --action set_nexthop --batch 1000 --workers 8`,
+	ValidArgsFunction: completeTableName,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTable(args[0])
+		if table == nil {
+			fmt.Printf("Can not found table with name: %s\n", args[0])
+			exit(1)
+		}
+		if importBatch <= 0 || importWorkers <= 0 {
+			fmt.Println("The --batch and --workers must be positive")
+			exit(1)
+		}
+
+		f, err := os.Open(importCsv)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		defer f.Close()
+		reader := csv.NewReader(f)
+		reader.FieldsPerRecord = -1
+		reader.ReuseRecord = true
+
+		names := importColumns
+		if len(names) == 0 {
+			header, err := reader.Read()
+			if err != nil {
+				fmt.Printf("Can not read the header of %s: %v\n", importCsv, err)
+				exit(1)
+			}
+			names = append([]string(nil), header...)
+		}
+		columns, err := parseImportColumns(table, names)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		updateType := p4.Update_INSERT
+		if importModify {
+			updateType = p4.Update_MODIFY
+		}
+
+		var written, failed int64
+		batches := make(chan updateBatch, importWorkers)
+		var wg sync.WaitGroup
+		for i := 0; i < importWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for b := range batches {
+					if err := writeUpdates(cli, ctx, b.updates...); err != nil {
+						fmt.Fprintf(os.Stderr, "%s rows %d-%d: %v\n", importCsv, b.first, b.last, err)
+						atomic.AddInt64(&failed, int64(len(b.updates)))
+						continue
+					}
+					atomic.AddInt64(&written, int64(len(b.updates)))
+				}
+			}()
+		}
+
+		start := time.Now()
+		done := make(chan struct{})
+		var progressed int32
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					atomic.StoreInt32(&progressed, 1)
+					n := atomic.LoadInt64(&written)
+					fmt.Fprintf(os.Stderr, "\rImported %d flows, %.0f flows/s", n, float64(n)/time.Since(start).Seconds())
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		batch := updateBatch{}
+		row := 0
+		if len(importColumns) == 0 {
+			row++
+		}
+		for ctx.Err() == nil {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			row++
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s row %d: %v\n", importCsv, row, err)
+				atomic.AddInt64(&failed, 1)
+				continue
+			}
+			update, err := importRow(table, columns, record, updateType)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s row %d: %v\n", importCsv, row, err)
+				atomic.AddInt64(&failed, 1)
+				continue
+			}
+			if len(batch.updates) == 0 {
+				batch.first = row
+			}
+			batch.last = row
+			batch.updates = append(batch.updates, update)
+			if len(batch.updates) == importBatch {
+				batches <- batch
+				batch = updateBatch{}
+			}
+		}
+		if len(batch.updates) != 0 {
+			batches <- batch
+		}
+		close(batches)
+		wg.Wait()
+		close(done)
+
+		elapsed := time.Since(start)
+		if atomic.LoadInt32(&progressed) != 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+		fmt.Printf("Imported %d flows into %s in %v (%.0f flows/s), %d failed\n",
+			written, table.Name, elapsed.Round(time.Millisecond), float64(written)/elapsed.Seconds(), failed)
+		if failed != 0 || ctx.Err() != nil {
+			exit(1)
+		}
+	},
+}
+
+// parseImportColumns checks the column names against the schema of the table.
+func parseImportColumns(table *util.Table, names []string) ([]importColumn, error) {
+	keys := keyFields(table)
+	hasAction := importAction != ""
+	columns := make([]importColumn, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		_, isKey := searchField(keys, name)
+		if name == ACTION_COLUMN {
+			if importAction != "" {
+				return nil, fmt.Errorf("the action column can not be used with --action")
+			}
+			hasAction = true
+		}
+		columns = append(columns, importColumn{name: name, isKey: isKey})
+	}
+	if !hasAction && len(actionSpecs(table)) != 0 {
+		return nil, fmt.Errorf("please specify the action by --action or an action column")
+	}
+	if importAction != "" {
+		action, ok := searchAction(table, importAction)
+		if !ok {
+			return nil, fmt.Errorf("can not found action %s in table %s", importAction, table.Name)
+		}
+		for _, c := range columns {
+			if c.name == "" || c.isKey {
+				continue
+			}
+			if _, ok := searchField(action.Params, c.name); ok {
+				continue
+			}
+			if _, ok := searchField(dataFields(table), c.name); !ok {
+				return nil, fmt.Errorf("column %s is neither a key field nor a parameter of action %s", c.name, action.Name)
+			}
+		}
+	}
+	return columns, nil
+}
+
+// importRow encodes a CSV row into the update of a flow.
+func importRow(table *util.Table, columns []importColumn, record []string, updateType p4.Update_Type) (*p4.Update, error) {
+	if len(record) > len(columns) {
+		return nil, fmt.Errorf("the row has %d columns but %d are named", len(record), len(columns))
+	}
+	var keyArgs, dataArgs []string
+	actionName := importAction
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+		c := columns[i]
+		switch {
+		case c.name == "" || value == "":
+		case c.isKey:
+			keyArgs = append(keyArgs, c.name+"="+value)
+		case c.name == ACTION_COLUMN:
+			actionName = value
+		default:
+			dataArgs = append(dataArgs, c.name+"="+value)
+		}
+	}
+
+	key, err := buildTableKey(table, keyArgs)
+	if err != nil {
+		return nil, err
+	}
+	var data *p4.TableData
+	if actionName != "" {
+		data, err = buildEntryData(table, append([]string{actionName}, dataArgs...))
+	} else {
+		data, err = buildTableData(table, dataArgs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p4.Update{Type: updateType, Entity: tableEntryEntity(table, key, data)}, nil
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVar(&importCsv, "csv", "", "The CSV file of flows")
+	importCmd.Flags().StringSliceVar(&importColumns, "columns", nil, "The names of CSV columns, default to the first row")
+	importCmd.Flags().StringVarP(&importAction, "action", "a", "", "The action of every flow when there is no action column")
+	importCmd.Flags().IntVar(&importBatch, "batch", 500, "The number of flows in a Write RPC")
+	importCmd.Flags().IntVar(&importWorkers, "workers", 4, "The number of concurrent Write RPCs")
+	importCmd.Flags().BoolVar(&importModify, "modify", false, "Modify the existing flows instead of adding")
+	importCmd.MarkFlagRequired("csv")
+	registerFlagCompletion(importCmd, "action", completeActionFlag)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/P4Networking/proto/go/p4"
+	"testing"
+)
+
+func TestParseImportColumns(t *testing.T) {
+	table := testTable(t, testLpmTable)
+	defer func() {
+		importAction = ""
+	}()
+	tests := []struct {
+		names   []string
+		action  string
+		keys    int
+		wantErr bool
+	}{
+		{[]string{"dst_addr", "action", "port", "dmac"}, "", 1, false},
+		{[]string{"dst_addr", "port", " dmac "}, "set_nexthop", 1, false},
+		{[]string{"dst_addr", "vrf", "", "port"}, "set_nexthop", 2, false},
+		{[]string{"dst_addr", "port"}, "", 0, true},
+		{[]string{"dst_addr", "action"}, "drop", 0, true},
+		{[]string{"dst_addr", "port", "dmac", "$COUNTER_SPEC_PKTS"}, "set_nexthop", 1, false},
+		{[]string{"dst_addr", "weight"}, "set_nexthop", 0, true},
+		{[]string{"dst_addr"}, "no_such_action", 0, true},
+	}
+	for _, tt := range tests {
+		importAction = tt.action
+		columns, err := parseImportColumns(table, tt.names)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseImportColumns(%q) with --action %q error = %v, want error %t", tt.names, tt.action, err, tt.wantErr)
+			continue
+		}
+		keys := 0
+		for _, c := range columns {
+			if c.isKey {
+				keys++
+			}
+		}
+		if keys != tt.keys {
+			t.Errorf("parseImportColumns(%q) has %d key columns, want %d", tt.names, keys, tt.keys)
+		}
+	}
+}
+
+func TestImportRow(t *testing.T) {
+	table := testTable(t, testLpmTable)
+	columns := []importColumn{{name: "dst_addr", isKey: true}, {name: "action"}, {name: "port"}, {name: "dmac"}, {name: ""}}
+	tests := []struct {
+		record  []string
+		action  uint32
+		params  int
+		wantErr bool
+	}{
+		{[]string{"10.0.0.0/8", "set_nexthop", "1", "00:00:00:00:00:01", "ignored"}, 10, 2, false},
+		{[]string{"10.0.0.0/8", "drop", "", ""}, 11, 0, false},
+		{[]string{" 10.1.0.0/16 ", "SwitchIngress.drop"}, 11, 0, false},
+		{[]string{"10.0.0.0/8", "set_nexthop", "1"}, 0, 0, true},
+		{[]string{"10.0.0.0/8", "set_nexthop", "1", "00:00:00:00:00:01", "x", "extra"}, 0, 0, true},
+		{[]string{"", "drop"}, 0, 0, true},
+		{[]string{"10.0.0.0/8", "forward"}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		update, err := importRow(table, columns, tt.record, p4.Update_INSERT)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("importRow(%q) error = %v, want error %t", tt.record, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		entry := update.Entity.GetTableEntry()
+		if update.Type != p4.Update_INSERT || entry.GetData().GetActionId() != tt.action || len(entry.GetData().GetFields()) != tt.params {
+			t.Errorf("importRow(%q) = action %d with %d params, want action %d with %d", tt.record,
+				entry.GetData().GetActionId(), len(entry.GetData().GetFields()), tt.action, tt.params)
+		}
+	}
+}
+
+func TestImportRowTableData(t *testing.T) {
+	table := testTable(t, testMeterTable)
+	columns := []importColumn{{name: "vrf", isKey: true}, {name: "action"}, {name: "nexthop_id"}, {name: "$METER_SPEC_CIR_KBPS"}}
+	update, err := importRow(table, columns, []string{"1", "set_nexthop", "5", "1000"}, p4.Update_INSERT)
+	if err != nil {
+		t.Fatalf("importRow with a direct meter column: %v", err)
+	}
+	data := update.Entity.GetTableEntry().GetData()
+	ids := map[uint32]bool{}
+	for _, f := range data.GetFields() {
+		ids[f.FieldId] = true
+	}
+	if data.GetActionId() != 10 || len(ids) != 2 || !ids[1] || !ids[65555] {
+		t.Errorf("importRow = action %d with fields %v, want action 10 with the parameter and the meter", data.GetActionId(), ids)
+	}
+}
@@ -23,19 +23,11 @@ import (
 
 // infoCmd represents the info command
 var infoCmd = &cobra.Command{
-	Use:   "info TABLE-NAME",
-	Args:  cobra.ExactArgs(1),
-	Short: "Show information about table",
-	Long:  `Display the detail of table.`,
-	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		_, _, conn, cancel, p4Info, _ := initConfigClient()
-		defer conn.Close()
-		defer cancel()
-
-		argsList, _ := p4Info.GuessTableName(toComplete)
-
-		return argsList, cobra.ShellCompDirectiveNoFileComp
-	},
+	Use:               "info TABLE-NAME",
+	Args:              cobra.ExactArgs(1),
+	Short:             "Show information about table",
+	Long:              `Display the detail of table.`,
+	ValidArgsFunction: completeTableName,
 	Run: func(cmd *cobra.Command, args []string) {
 		//fmt.Printf("Got cmd: %s | and args: %s\n", cmd.Name(), args)
 		var tableName string
@@ -50,7 +42,7 @@ var infoCmd = &cobra.Command{
 			tableList, ok = nonP4Info.GuessTableName(args[0])
 			if !ok {
 				fmt.Printf("Not found the table %s\n", args[0])
-				return
+				exit(1)
 			}
 		}
 		tableName = tableList[0]
@@ -58,7 +50,7 @@ var infoCmd = &cobra.Command{
 		tableId := p4Info.SearchTableId(tableName)
 		if tableId == util.ID_NOT_FOUND {
 			fmt.Printf("Can not found table with name: %s\n", tableName)
-			return
+			exit(1)
 		}
 
 		table := p4Info.SearchTableById(tableId)
@@ -82,7 +74,7 @@ var infoCmd = &cobra.Command{
 			}
 		}
 		if table.DependsOn != nil {
-			fmt.Printf("%-12s: %-6s\n", "Table DependsOn", table.DependsOn)
+			fmt.Printf("%-12s: %v\n", "Table DependsOn", table.DependsOn)
 		}
 
 		fmt.Println("==================================================================================================================================")
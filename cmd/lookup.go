@@ -0,0 +1,153 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"math/big"
+	"strings"
+)
+
+const MATCH_PRIORITY = "$MATCH_PRIORITY"
+
+// lookupCmd represents the lookup command
+var lookupCmd = &cobra.Command{
+	Use:   "lookup TABLE-NAME KEY=VALUE...",
+	Args:  cobra.MinimumNArgs(2),
+	Short: "Find the flow which a packet would hit",
+	Long: `Read the flows of a table and compute which one a packet with the given
+header values would hit. Every key field except $MATCH_PRIORITY must be given.
+The longest prefix wins among LPM flows, and the lowest $MATCH_PRIORITY wins
+among ternary and range flows. When no flow matches, the default action is
+shown.
+
+  bfcli lookup SwitchIngress.ipv4_lpm ipv4_dst=10.1.2.3`,
+	ValidArgsFunction: completeTableArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTable(args[0])
+		if table == nil {
+			fmt.Printf("Can not found table with name: %s\n", args[0])
+			exit(1)
+		}
+
+		filters, err := parseMatchFilters(table, args[1:])
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		var missing []string
+		for _, k := range keyFields(table) {
+			found := k.Name == MATCH_PRIORITY
+			for _, f := range filters {
+				if f.field.ID == k.ID {
+					found = true
+				}
+			}
+			if !found {
+				missing = append(missing, k.Name)
+			}
+		}
+		if len(missing) != 0 {
+			fmt.Printf("The value of key fields %s are required\n", strings.Join(missing, ", "))
+			exit(1)
+		}
+		for _, f := range filters {
+			if f.prefixLen >= 0 {
+				fmt.Printf("The value of %s must be an address rather than a prefix\n", f.field.Name)
+				exit(1)
+			}
+		}
+
+		priority, hasPriority := searchField(keyFields(table), MATCH_PRIORITY)
+		var hit *p4.TableEntry
+		candidates := 0
+		for _, e := range readEntities(cli, ctx, &p4.Entity{
+			Entity: &p4.Entity_TableEntry{
+				TableEntry: &p4.TableEntry{TableId: table.ID},
+			},
+		}) {
+			tbl := e.GetTableEntry()
+			if tbl == nil || len(tbl.GetKey().GetFields()) == 0 || !matchFilters(filters, tbl) {
+				continue
+			}
+			candidates++
+			if hit == nil || betterLookupHit(tbl, hit, priority.ID, hasPriority) {
+				hit = tbl
+			}
+		}
+
+		if hit == nil {
+			fmt.Printf("%-12s: %s\n", "Result", "miss")
+			def, err := tryReadEntities(cli, ctx, &p4.Entity{
+				Entity: &p4.Entity_TableEntry{
+					TableEntry: &p4.TableEntry{TableId: table.ID, IsDefaultEntry: true},
+				},
+			})
+			if err == nil && len(def) != 0 && def[0].GetTableEntry() != nil {
+				fmt.Printf("%-12s: %s\n", "Action", formatEntryData(table, def[0].GetTableEntry().GetData()))
+			}
+			return
+		}
+		fmt.Printf("%-12s: %s\n", "Result", "hit")
+		fmt.Printf("%-12s: %d\n", "Candidates", candidates)
+		fmt.Printf("%-12s: %s\n", "Match Key", formatTableKey(table, hit.GetKey()))
+		fmt.Printf("%-12s: %s\n", "Action", formatEntryData(table, hit.GetData()))
+	},
+}
+
+// betterLookupHit reports whether entry a takes precedence over entry b,
+// by $MATCH_PRIORITY when the table has one, otherwise by the total prefix
+// length of its LPM fields.
+func betterLookupHit(a, b *p4.TableEntry, priorityId uint32, hasPriority bool) bool {
+	if hasPriority {
+		pa, pb := keyFieldValue(a, priorityId), keyFieldValue(b, priorityId)
+		if c := pa.Cmp(pb); c != 0 {
+			return c < 0
+		}
+	}
+	return prefixLength(a) > prefixLength(b)
+}
+
+func keyFieldValue(tbl *p4.TableEntry, id uint32) *big.Int {
+	for _, f := range tbl.GetKey().GetFields() {
+		if f.FieldId == id {
+			return decodeValue(f.GetExact().GetValue())
+		}
+	}
+	return new(big.Int)
+}
+
+func prefixLength(tbl *p4.TableEntry) int {
+	total := 0
+	for _, f := range tbl.GetKey().GetFields() {
+		if lpm := f.GetLpm(); lpm != nil {
+			total += int(lpm.GetPrefixLen())
+		}
+	}
+	return total
+}
+
+func init() {
+	rootCmd.AddCommand(lookupCmd)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/P4Networking/proto/go/p4"
+	"testing"
+)
+
+// lookupEntry builds an entry with an LPM field of the prefix length and an
+// exact $MATCH_PRIORITY field of ID 2.
+func lookupEntry(prefixLen int32, priority byte) *p4.TableEntry {
+	return &p4.TableEntry{Key: &p4.TableKey{Fields: []*p4.KeyField{
+		{FieldId: 1, MatchType: &p4.KeyField_Lpm{Lpm: &p4.KeyField_LPM{Value: []byte{10, 0, 0, 0}, PrefixLen: prefixLen}}},
+		{FieldId: 2, MatchType: &p4.KeyField_Exact_{Exact: &p4.KeyField_Exact{Value: []byte{priority}}}},
+	}}}
+}
+
+func TestBetterLookupHit(t *testing.T) {
+	tests := []struct {
+		name        string
+		a, b        *p4.TableEntry
+		hasPriority bool
+		want        bool
+	}{
+		{"longer prefix", lookupEntry(24, 0), lookupEntry(16, 0), false, true},
+		{"shorter prefix", lookupEntry(8, 0), lookupEntry(16, 0), false, false},
+		{"same prefix", lookupEntry(16, 0), lookupEntry(16, 0), false, false},
+		{"lower priority value wins", lookupEntry(8, 1), lookupEntry(24, 5), true, true},
+		{"higher priority value loses", lookupEntry(24, 5), lookupEntry(8, 1), true, false},
+		{"equal priority falls back to prefix", lookupEntry(24, 3), lookupEntry(16, 3), true, true},
+		{"priority ignored without the field", lookupEntry(8, 1), lookupEntry(24, 5), false, false},
+	}
+	for _, tt := range tests {
+		if got := betterLookupHit(tt.a, tt.b, 2, tt.hasPriority); got != tt.want {
+			t.Errorf("%s: betterLookupHit = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
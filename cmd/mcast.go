@@ -0,0 +1,360 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"strconv"
+	"strings"
+)
+
+const (
+	PRE_MGID_TABLE              = "$pre.mgid"
+	PRE_NODE_TABLE              = "$pre.node"
+	MGID                        = "$MGID"
+	MULTICAST_NODE_ID           = "$MULTICAST_NODE_ID"
+	MULTICAST_NODE_L1_XID_VALID = "$MULTICAST_NODE_L1_XID_VALID"
+	MULTICAST_NODE_L1_XID       = "$MULTICAST_NODE_L1_XID"
+	MULTICAST_RID               = "$MULTICAST_RID"
+	MULTICAST_LAG_ID            = "$MULTICAST_LAG_ID"
+	DEV_PORT                    = "$DEV_PORT"
+)
+
+var (
+	mcastNodes []uint
+	mcastRid   uint16
+	mcastPorts []uint
+)
+
+// mcastNode is a L1 node of the packet replication engine.
+type mcastNode struct {
+	id    uint32
+	rid   uint64
+	lags  []uint32
+	ports []uint32
+}
+
+// mcastCmd represents the mcast command
+var mcastCmd = &cobra.Command{
+	Use:   "mcast",
+	Short: "Manage multicast groups of packet replication engine",
+	Long: `Manage the multicast groups and L1 nodes of packet replication engine through
+the $pre.mgid and $pre.node tables.`,
+}
+
+var mcastGroupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manage multicast groups",
+	Long:  `Add, delete or show multicast groups`,
+}
+
+var mcastNodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "Manage multicast L1 nodes",
+	Long:  `Add or delete multicast L1 nodes`,
+}
+
+var mcastGroupAddCmd = &cobra.Command{
+	Use:   "add MGID",
+	Args:  cobra.ExactArgs(1),
+	Short: "Add a multicast group",
+	Long: `Add a multicast group with L1 nodes.
+
+  bfcli mcast group add 1 --node 1,2`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table, key := mcastTarget(PRE_MGID_TABLE, MGID, args[0])
+		if table == nil {
+			exit(1)
+		}
+		var nodes, valid, xids []string
+		for _, n := range mcastNodes {
+			nodes = append(nodes, strconv.FormatUint(uint64(n), 10))
+			valid = append(valid, "false")
+			xids = append(xids, "0")
+		}
+		var assignments []string
+		if len(nodes) != 0 {
+			assignments = []string{
+				fmt.Sprintf("%s=%s", MULTICAST_NODE_ID, strings.Join(nodes, ",")),
+				fmt.Sprintf("%s=%s", MULTICAST_NODE_L1_XID_VALID, strings.Join(valid, ",")),
+				fmt.Sprintf("%s=%s", MULTICAST_NODE_L1_XID, strings.Join(xids, ",")),
+			}
+		}
+		data, err := buildTableData(table, assignments)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		err = writeUpdates(cli, ctx, &p4.Update{
+			Type:   p4.Update_INSERT,
+			Entity: tableEntryEntity(table, key, data),
+		})
+		if err != nil {
+			fatalf("Got error when adding multicast group: %v", err)
+		}
+		fmt.Printf("Multicast group %s is added\n", args[0])
+	},
+}
+
+var mcastGroupDelCmd = &cobra.Command{
+	Use:   "del MGID",
+	Args:  cobra.ExactArgs(1),
+	Short: "Delete a multicast group",
+	Long:  `Delete a multicast group, the L1 nodes of the group are kept`,
+	Run: func(cmd *cobra.Command, args []string) {
+		mcastDelete(PRE_MGID_TABLE, MGID, args[0])
+	},
+}
+
+var mcastGroupShowCmd = &cobra.Command{
+	Use:   "show [MGID]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "Show multicast groups",
+	Long: `Show multicast groups as a tree of MGID, L1 nodes and ports.
+
+  bfcli mcast group show
+  bfcli mcast group show 1`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		var table *util.Table
+		var key *p4.TableKey
+		if len(args) == 1 {
+			table, key = mcastTarget(PRE_MGID_TABLE, MGID, args[0])
+		} else {
+			table = findNonP4Table(PRE_MGID_TABLE)
+		}
+		nodeTable := findNonP4Table(PRE_NODE_TABLE)
+		if table == nil || nodeTable == nil {
+			exit(1)
+		}
+
+		nodes := map[uint32]mcastNode{}
+		for _, n := range readMcastNodes(cli, ctx, nodeTable) {
+			nodes[n.id] = n
+		}
+
+		keyField, _ := searchField(keyFields(table), MGID)
+		nodeField, _ := searchField(dataFields(table), MULTICAST_NODE_ID)
+		groups := readEntities(cli, ctx, tableEntryEntity(table, key, nil))
+		if len(groups) == 0 {
+			fmt.Println("The multicast groups is null")
+			return
+		}
+		for _, e := range groups {
+			tbl := e.GetTableEntry()
+			if tbl == nil {
+				continue
+			}
+			for _, f := range tbl.GetKey().GetFields() {
+				if f.FieldId == keyField.ID {
+					fmt.Printf("MGID %s\n", decodeValue(f.GetExact().GetValue()))
+				}
+			}
+			ids := collectDataValues(tbl.GetData().GetFields(), nodeField.ID)
+			for i, v := range ids {
+				branch, indent := "├──", "│   "
+				if i == len(ids)-1 {
+					branch, indent = "└──", "    "
+				}
+				node, ok := nodes[uint32(v.Uint64())]
+				if !ok {
+					fmt.Printf("%s node %s (missing)\n", branch, v)
+					continue
+				}
+				fmt.Printf("%s node %d (rid %d)\n", branch, node.id, node.rid)
+				for j, p := range node.ports {
+					leaf := "├──"
+					if j == len(node.ports)-1 && len(node.lags) == 0 {
+						leaf = "└──"
+					}
+					fmt.Printf("%s%s port %d\n", indent, leaf, p)
+				}
+				for j, l := range node.lags {
+					leaf := "├──"
+					if j == len(node.lags)-1 {
+						leaf = "└──"
+					}
+					fmt.Printf("%s%s lag %d\n", indent, leaf, l)
+				}
+			}
+		}
+	},
+}
+
+var mcastNodeAddCmd = &cobra.Command{
+	Use:   "add NODE-ID",
+	Args:  cobra.ExactArgs(1),
+	Short: "Add a multicast L1 node",
+	Long: `Add a multicast L1 node with replication ID and device ports.
+
+  bfcli mcast node add 1 --rid 10 --ports 0,1,2`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table, key := mcastTarget(PRE_NODE_TABLE, MULTICAST_NODE_ID, args[0])
+		if table == nil {
+			exit(1)
+		}
+		assignments := []string{fmt.Sprintf("%s=%d", MULTICAST_RID, mcastRid)}
+		if len(mcastPorts) != 0 {
+			var ports []string
+			for _, p := range mcastPorts {
+				ports = append(ports, strconv.FormatUint(uint64(p), 10))
+			}
+			assignments = append(assignments, fmt.Sprintf("%s=%s", DEV_PORT, strings.Join(ports, ",")))
+		}
+		data, err := buildTableData(table, assignments)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		err = writeUpdates(cli, ctx, &p4.Update{
+			Type:   p4.Update_INSERT,
+			Entity: tableEntryEntity(table, key, data),
+		})
+		if err != nil {
+			fatalf("Got error when adding multicast node: %v", err)
+		}
+		fmt.Printf("Multicast node %s is added\n", args[0])
+	},
+}
+
+var mcastNodeDelCmd = &cobra.Command{
+	Use:   "del NODE-ID",
+	Args:  cobra.ExactArgs(1),
+	Short: "Delete a multicast L1 node",
+	Long:  `Delete a multicast L1 node, the node must not be in any multicast group`,
+	Run: func(cmd *cobra.Command, args []string) {
+		mcastDelete(PRE_NODE_TABLE, MULTICAST_NODE_ID, args[0])
+	},
+}
+
+// findNonP4Table finds a table such as $pre.mgid or $PORT in the non-P4
+// tables, it prints the reason and returns nil on failure.
+func findNonP4Table(name string) *util.Table {
+	for i := range nonP4Info.Tables {
+		if nonP4Info.Tables[i].Name == name {
+			return &nonP4Info.Tables[i]
+		}
+	}
+	fmt.Printf("The device does not provide the table %s\n", name)
+	return nil
+}
+
+func mcastTarget(tableName, keyName, id string) (*util.Table, *p4.TableKey) {
+	table := findNonP4Table(tableName)
+	if table == nil {
+		return nil, nil
+	}
+	key, err := buildTableKey(table, []string{fmt.Sprintf("%s=%s", keyName, id)})
+	if err != nil {
+		fmt.Println(err)
+		return nil, nil
+	}
+	return table, key
+}
+
+func mcastDelete(tableName, keyName, id string) {
+	cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+	defer conn.Close()
+	defer cancel()
+	cli := *cliAddr
+	ctx := *ctxAddr
+
+	table, key := mcastTarget(tableName, keyName, id)
+	if table == nil {
+		exit(1)
+	}
+	err := writeUpdates(cli, ctx, &p4.Update{
+		Type:   p4.Update_DELETE,
+		Entity: tableEntryEntity(table, key, nil),
+	})
+	if err != nil {
+		fatalf("Got error when deleting %s: %v", id, err)
+	}
+	fmt.Printf("The %s %s is deleted from %s\n", keyName, id, table.Name)
+}
+
+func readMcastNodes(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table) []mcastNode {
+	keyField, _ := searchField(keyFields(table), MULTICAST_NODE_ID)
+	data := dataFields(table)
+	rid, _ := searchField(data, MULTICAST_RID)
+	lags, _ := searchField(data, MULTICAST_LAG_ID)
+	ports, _ := searchField(data, DEV_PORT)
+
+	var nodes []mcastNode
+	for _, e := range readEntities(cli, ctx, tableEntryEntity(table, nil, nil)) {
+		tbl := e.GetTableEntry()
+		if tbl == nil {
+			continue
+		}
+		var node mcastNode
+		for _, f := range tbl.GetKey().GetFields() {
+			if f.FieldId == keyField.ID {
+				node.id = uint32(decodeValue(f.GetExact().GetValue()).Uint64())
+			}
+		}
+		fields := tbl.GetData().GetFields()
+		if v := collectDataValues(fields, rid.ID); len(v) != 0 {
+			node.rid = v[0].Uint64()
+		}
+		for _, v := range collectDataValues(fields, lags.ID) {
+			node.lags = append(node.lags, uint32(v.Uint64()))
+		}
+		for _, v := range collectDataValues(fields, ports.ID) {
+			node.ports = append(node.ports, uint32(v.Uint64()))
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func init() {
+	rootCmd.AddCommand(mcastCmd)
+	mcastCmd.AddCommand(mcastGroupCmd)
+	mcastCmd.AddCommand(mcastNodeCmd)
+	mcastGroupCmd.AddCommand(mcastGroupAddCmd)
+	mcastGroupCmd.AddCommand(mcastGroupDelCmd)
+	mcastGroupCmd.AddCommand(mcastGroupShowCmd)
+	mcastNodeCmd.AddCommand(mcastNodeAddCmd)
+	mcastNodeCmd.AddCommand(mcastNodeDelCmd)
+
+	mcastGroupAddCmd.Flags().UintSliceVar(&mcastNodes, "node", nil, "The L1 node IDs of the group, e.g. 1,2")
+	mcastNodeAddCmd.Flags().Uint16Var(&mcastRid, "rid", 0, "The replication ID of the node")
+	mcastNodeAddCmd.Flags().UintSliceVar(&mcastPorts, "ports", nil, "The device ports of the node, e.g. 0,1,2")
+	mcastNodeAddCmd.MarkFlagRequired("rid")
+}
@@ -0,0 +1,394 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	METER_TABLE_TYPE = "Meter"
+	METER_INDEX      = "$METER_INDEX"
+)
+
+// meterSpec describes the data fields of a meter, which are declared in
+// kbps/kbits for byte meters and in pps/pkts for packet meters.
+type meterSpec struct {
+	packets bool
+	cir     fieldInfo
+	pir     fieldInfo
+	cbs     fieldInfo
+	pbs     fieldInfo
+}
+
+var (
+	meterIndex uint32
+	meterCir   string
+	meterPir   string
+	meterCbs   string
+	meterPbs   string
+
+	// The units are scaled to the native unit of meter, kbps and kbits for
+	// byte meters, pps and pkts for packet meters.
+	byteRateUnits    = map[string]float64{"bps": 0.001, "kbps": 1, "Kbps": 1, "Mbps": 1000, "Gbps": 1000000}
+	packetRateUnits  = map[string]float64{"pps": 1, "kpps": 1000, "Kpps": 1000, "Mpps": 1000000}
+	byteBurstUnits   = map[string]float64{"B": 0.008, "KB": 8, "kB": 8, "MB": 8000, "bits": 0.001, "kbits": 1, "Kbits": 1, "Mbits": 1000}
+	packetBurstUnits = map[string]float64{"pkts": 1, "kpkts": 1000, "Kpkts": 1000}
+)
+
+// meterCmd represents the meter command
+var meterCmd = &cobra.Command{
+	Use:   "meter",
+	Short: "Configure or inspect meters",
+	Long: `Configure or inspect both indirect meter tables and direct meters attached to
+match tables. Indirect meters are addressed by --index, direct meters by the
+match key of the entry.`,
+}
+
+var meterSetCmd = &cobra.Command{
+	Use:   "set METER-NAME [KEY=VALUE...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Configure a meter",
+	Long: `Configure the rates and burst sizes of a meter. The values accept units,
+rates in bps, kbps, Mbps, Gbps for byte meters or pps, kpps, Mpps for packet
+meters, and burst sizes in B, KB, MB, kbits for byte meters or pkts, kpkts for
+packet meters. A value without unit is taken in the native unit of the meter.
+Values which are not given keep their current setting.
+
+  bfcli meter set SwitchIngress.meter --index 1 --cir 10Mbps --cbs 1500B --pir 20Mbps --pbs 3000B
+  bfcli meter set SwitchIngress.acl ipv4_dst=10.0.0.1 --cir 10kpps --pir 20kpps`,
+	ValidArgsFunction: completeMeterName,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table, spec, key := meterTarget(cmd, args)
+		if table == nil {
+			exit(1)
+		}
+
+		current := readMeterEntry(cli, ctx, table, key)
+		if current == nil {
+			fmt.Printf("Can not found the meter entry in %s\n", table.Name)
+			exit(1)
+		}
+
+		values := map[uint32]uint64{}
+		for _, f := range []fieldInfo{spec.cir, spec.pir, spec.cbs, spec.pbs} {
+			if v := collectDataValues(current.GetData().GetFields(), f.ID); len(v) > 0 {
+				values[f.ID] = v[0].Uint64()
+			}
+		}
+		for _, s := range []struct {
+			flag  string
+			value string
+			field fieldInfo
+			burst bool
+		}{
+			{"cir", meterCir, spec.cir, false},
+			{"pir", meterPir, spec.pir, false},
+			{"cbs", meterCbs, spec.cbs, true},
+			{"pbs", meterPbs, spec.pbs, true},
+		} {
+			if !cmd.Flags().Changed(s.flag) {
+				continue
+			}
+			v, err := parseMeterValue(s.value, spec.packets, s.burst)
+			if err != nil {
+				fmt.Printf("Invalid --%s: %v\n", s.flag, err)
+				exit(1)
+			}
+			values[s.field.ID] = v
+		}
+		// A stored PIR of zero means the peak rate was never configured, so
+		// only a given or configured PIR is compared against the CIR.
+		pirSet := cmd.Flags().Changed("pir") || values[spec.pir.ID] != 0
+		if pirSet && values[spec.pir.ID] < values[spec.cir.ID] {
+			fmt.Printf("The PIR %d must not be lower than CIR %d\n", values[spec.pir.ID], values[spec.cir.ID])
+			exit(1)
+		}
+
+		tableData := &p4.TableData{}
+		if table.TableType != METER_TABLE_TYPE {
+			// Direct meters are part of the match entry, so keep the action and
+			// its parameters while replacing the meter spec.
+			data := dataFields(table)
+			tableData.ActionId = current.GetData().GetActionId()
+			for _, d := range current.GetData().GetFields() {
+				if _, ok := searchFieldById(data, d.FieldId); !ok {
+					tableData.Fields = append(tableData.Fields, d)
+				}
+			}
+		}
+		for _, f := range []fieldInfo{spec.cir, spec.pir, spec.cbs, spec.pbs} {
+			stream, err := encodeValue(strconv.FormatUint(values[f.ID], 10), f.bitWidth())
+			if err != nil {
+				fmt.Printf("Invalid value of %s: %v\n", f.Name, err)
+				exit(1)
+			}
+			tableData.Fields = append(tableData.Fields, &p4.DataField{
+				FieldId: f.ID,
+				Value:   &p4.DataField_Stream{Stream: stream},
+			})
+		}
+
+		err := writeUpdates(cli, ctx, &p4.Update{
+			Type: p4.Update_MODIFY,
+			Entity: &p4.Entity{
+				Entity: &p4.Entity_TableEntry{
+					TableEntry: &p4.TableEntry{
+						TableId: table.ID,
+						Key:     key,
+						Data:    tableData,
+					},
+				},
+			},
+		})
+		if err != nil {
+			fatalf("Got error when configuring meter: %v", err)
+		}
+		fmt.Printf("Meter of %s is configured\n", table.Name)
+	},
+}
+
+var meterGetCmd = &cobra.Command{
+	Use:   "get METER-NAME [KEY=VALUE...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Show the configuration of a meter",
+	Long: `Show the rates and burst sizes of a meter.
+
+  bfcli meter get SwitchIngress.meter --index 1
+  bfcli meter get SwitchIngress.acl ipv4_dst=10.0.0.1`,
+	ValidArgsFunction: completeMeterName,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table, spec, key := meterTarget(cmd, args)
+		if table == nil {
+			exit(1)
+		}
+
+		current := readMeterEntry(cli, ctx, table, key)
+		if current == nil {
+			fmt.Printf("Can not found the meter entry in %s\n", table.Name)
+			exit(1)
+		}
+
+		rateUnit, burstUnit := "kbps", "kbits"
+		if spec.packets {
+			rateUnit, burstUnit = "pps", "pkts"
+		}
+		fmt.Printf("%-12s: %s\n", "Meter Name", table.Name)
+		if table.TableType == METER_TABLE_TYPE {
+			fmt.Printf("%-12s: %d\n", "Index", meterIndex)
+		} else {
+			fmt.Printf("%-12s: %s\n", "Match Key", strings.Join(args[1:], " "))
+		}
+		for _, s := range []struct {
+			label string
+			field fieldInfo
+			unit  string
+		}{
+			{"CIR", spec.cir, rateUnit},
+			{"PIR", spec.pir, rateUnit},
+			{"CBS", spec.cbs, burstUnit},
+			{"PBS", spec.pbs, burstUnit},
+		} {
+			v := collectDataValues(current.GetData().GetFields(), s.field.ID)
+			if len(v) == 0 {
+				fmt.Printf("%-12s: -\n", s.label)
+				continue
+			}
+			fmt.Printf("%-12s: %s %s\n", s.label, v[0], s.unit)
+		}
+	},
+}
+
+func completeMeterName(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	_, _, conn, cancel, p4Info, _ := initConfigClient()
+	defer conn.Close()
+	defer cancel()
+
+	var argsList []string
+	for i := range p4Info.Tables {
+		t := &p4Info.Tables[i]
+		if _, ok := searchMeterSpec(t); ok && strings.HasPrefix(t.Name, toComplete) {
+			argsList = append(argsList, t.Name)
+		}
+	}
+	return argsList, cobra.ShellCompDirectiveNoFileComp
+}
+
+// searchMeterSpec finds the meter data fields of an indirect meter table or
+// of a match table with a direct meter.
+func searchMeterSpec(table *util.Table) (meterSpec, bool) {
+	data := dataFields(table)
+	for _, packets := range []bool{false, true} {
+		names := []string{"$METER_SPEC_CIR_KBPS", "$METER_SPEC_PIR_KBPS", "$METER_SPEC_CBS_KBITS", "$METER_SPEC_PBS_KBITS"}
+		if packets {
+			names = []string{"$METER_SPEC_CIR_PPS", "$METER_SPEC_PIR_PPS", "$METER_SPEC_CBS_PKTS", "$METER_SPEC_PBS_PKTS"}
+		}
+		var fields []fieldInfo
+		for _, n := range names {
+			if f, ok := searchField(data, n); ok {
+				fields = append(fields, f)
+			}
+		}
+		if len(fields) == len(names) {
+			return meterSpec{packets: packets, cir: fields[0], pir: fields[1], cbs: fields[2], pbs: fields[3]}, FOUND
+		}
+	}
+	return meterSpec{}, NOT_FOUND
+}
+
+// meterTarget resolves the meter table and the key of the meter entry from
+// the command line, it prints the reason and returns nil table on failure.
+func meterTarget(cmd *cobra.Command, args []string) (*util.Table, meterSpec, *p4.TableKey) {
+	table := findTable(args[0])
+	if table == nil {
+		fmt.Printf("Can not found table with name: %s\n", args[0])
+		return nil, meterSpec{}, nil
+	}
+	spec, ok := searchMeterSpec(table)
+	if !ok {
+		fmt.Printf("The table %s has no meter\n", table.Name)
+		return nil, meterSpec{}, nil
+	}
+
+	if table.TableType == METER_TABLE_TYPE {
+		if !cmd.Flags().Changed("index") {
+			fmt.Printf("The --index is required by indirect meter %s\n", table.Name)
+			return nil, meterSpec{}, nil
+		}
+		if table.Size != 0 && int(meterIndex) >= int(table.Size) {
+			fmt.Printf("The index %d is out of meter size %d\n", meterIndex, table.Size)
+			return nil, meterSpec{}, nil
+		}
+		key, err := buildTableKey(table, []string{fmt.Sprintf("%s=%d", METER_INDEX, meterIndex)})
+		if err != nil {
+			fmt.Println(err)
+			return nil, meterSpec{}, nil
+		}
+		return table, spec, key
+	}
+
+	if len(args) < 2 {
+		fmt.Printf("The match key is required by direct meter of %s\n", table.Name)
+		return nil, meterSpec{}, nil
+	}
+	key, err := buildTableKey(table, args[1:])
+	if err != nil {
+		fmt.Println(err)
+		return nil, meterSpec{}, nil
+	}
+	return table, spec, key
+}
+
+func readMeterEntry(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table, key *p4.TableKey) *p4.TableEntry {
+	entities := readEntities(cli, ctx, &p4.Entity{
+		Entity: &p4.Entity_TableEntry{
+			TableEntry: &p4.TableEntry{
+				TableId: table.ID,
+				Key:     key,
+			},
+		},
+	})
+	for _, e := range entities {
+		if tbl := e.GetTableEntry(); tbl != nil {
+			return tbl
+		}
+	}
+	return nil
+}
+
+// parseMeterValue converts a value such as "10Mbps" or "1500B" to the native
+// unit of the meter and rejects units of the wrong meter type.
+func parseMeterValue(s string, packets, burst bool) (uint64, error) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, unit := s, ""
+	if i >= 0 {
+		number, unit = s[:i], s[i:]
+	}
+	if number == "" {
+		return 0, fmt.Errorf("missing number in %q", s)
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number in %q", s)
+	}
+	if unit == "" {
+		if n != math.Trunc(n) {
+			return 0, fmt.Errorf("fractional value %q needs a unit", s)
+		}
+		return uint64(n), nil
+	}
+
+	units, other := byteRateUnits, packetRateUnits
+	if burst {
+		units, other = byteBurstUnits, packetBurstUnits
+	}
+	if packets {
+		units, other = other, units
+	}
+	scale, ok := units[unit]
+	if !ok {
+		if _, ok := other[unit]; ok {
+			if packets {
+				return 0, fmt.Errorf("unit %s is for byte meters, but the meter counts packets", unit)
+			}
+			return 0, fmt.Errorf("unit %s is for packet meters, but the meter counts bytes", unit)
+		}
+		return 0, fmt.Errorf("unknown unit %s", unit)
+	}
+	value := math.Round(n * scale)
+	if n != 0 && value == 0 {
+		return 0, fmt.Errorf("%q is below the smallest value of the meter", s)
+	}
+	return uint64(value), nil
+}
+
+func init() {
+	rootCmd.AddCommand(meterCmd)
+	meterCmd.AddCommand(meterSetCmd)
+	meterCmd.AddCommand(meterGetCmd)
+
+	meterSetCmd.Flags().Uint32VarP(&meterIndex, "index", "i", 0, "The index of indirect meter")
+	meterSetCmd.Flags().StringVar(&meterCir, "cir", "", "Committed information rate, e.g. 10Mbps or 10kpps")
+	meterSetCmd.Flags().StringVar(&meterPir, "pir", "", "Peak information rate, e.g. 20Mbps or 20kpps")
+	meterSetCmd.Flags().StringVar(&meterCbs, "cbs", "", "Committed burst size, e.g. 1500B or 10pkts")
+	meterSetCmd.Flags().StringVar(&meterPbs, "pbs", "", "Peak burst size, e.g. 3000B or 20pkts")
+
+	meterGetCmd.Flags().Uint32VarP(&meterIndex, "index", "i", 0, "The index of indirect meter")
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"testing"
+)
+
+func TestParseMeterValue(t *testing.T) {
+	tests := []struct {
+		s       string
+		packets bool
+		burst   bool
+		want    uint64
+		wantErr bool
+	}{
+		{"10Mbps", false, false, 10000, false},
+		{"1.5Gbps", false, false, 1500000, false},
+		{"1500B", false, true, 12, false},
+		{"20kbits", false, true, 20, false},
+		{"10kpps", true, false, 10000, false},
+		{"5pkts", true, true, 5, false},
+		{"42", false, false, 42, false},
+		{"10kpps", false, false, 0, true},
+		{"10Mbps", true, false, 0, true},
+		{"10B", false, false, 0, true},
+		{"10furlongs", false, false, 0, true},
+		{"Mbps", false, false, 0, true},
+		{"1.2.3Mbps", false, false, 0, true},
+		{"400bps", false, false, 0, true},
+		{"600bps", false, false, 1, false},
+		{"0bps", false, false, 0, false},
+		{"50B", false, true, 0, true},
+		{"42.5", false, false, 0, true},
+		{"42.0", false, false, 42, false},
+	}
+	for _, tt := range tests {
+		got, err := parseMeterValue(tt.s, tt.packets, tt.burst)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMeterValue(%q, %t, %t) error = %v, want error %t", tt.s, tt.packets, tt.burst, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseMeterValue(%q, %t, %t) = %d, want %d", tt.s, tt.packets, tt.burst, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+)
+
+// modFlowCmd represents the modFlow command
+var modFlowCmd = &cobra.Command{
+	Use:   "mod-flow TABLE-NAME KEY=VALUE...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Modify a flow in specify table",
+	Long: `Replace the action and parameters, or the referenced action profile member or
+selector group, of the flow with the given match key.
+
+  bfcli mod-flow SwitchIngress.ipv4_lpm ipv4_dst=10.0.0.0/8 --action set_nexthop -p port=2
+  bfcli mod-flow SwitchIngress.ipv4_ecmp ipv4_dst=10.0.0.0/8 --group 2`,
+	ValidArgsFunction: completeTableArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTable(args[0])
+		if table == nil {
+			fmt.Printf("Can not found table with name: %s\n", args[0])
+			exit(1)
+		}
+
+		key, err := buildTableKey(table, args[1:])
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		data, err := buildFlowData(cmd, table)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		err = writeUpdates(cli, ctx, &p4.Update{
+			Type:   p4.Update_MODIFY,
+			Entity: tableEntryEntity(table, key, data),
+		})
+		if err != nil {
+			fatalf("Got error when modifying flow: %v", err)
+		}
+		fmt.Printf("The flow in %s is modified\n", table.Name)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(modFlowCmd)
+	modFlowCmd.Flags().StringVarP(&setFlowAction, "action", "a", "", "The action of the flow")
+	modFlowCmd.Flags().StringArrayVarP(&setFlowParams, "param", "p", nil, "The action parameter NAME=VALUE, can be repeated")
+	modFlowCmd.Flags().Uint32Var(&setFlowMember, "member", 0, "The action profile member ID referenced by the flow")
+	modFlowCmd.Flags().Uint32Var(&setFlowGroup, "group", 0, "The selector group ID referenced by the flow")
+	registerFlagCompletion(modFlowCmd, "action", completeActionFlag)
+	registerFlagCompletion(modFlowCmd, "param", completeParamFlag)
+}
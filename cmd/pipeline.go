@@ -0,0 +1,261 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"strings"
+)
+
+const DEFAULT_PIPELINE_ACTION = "verify_and_warm_init_begin_and_end"
+
+var (
+	pipelineName     string
+	pipelineBfrt     string
+	pipelineContext  string
+	pipelineBinary   string
+	pipelineProfile  string
+	pipelinePipes    []uint
+	pipelineAction   string
+	pipelineBasePath string
+	pipelineClientId uint32
+)
+
+// pipelineCmd represents the pipeline command
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Deploy or show the forwarding pipeline",
+	Long:  `Deploy the compiler outputs of a P4 program to the device, or show the loaded program`,
+}
+
+var pipelinePushCmd = &cobra.Command{
+	Use:   "push",
+	Args:  cobra.ExactArgs(0),
+	Short: "Push a P4 program to the device",
+	Long: `Package the bf-rt.json, context.json and tofino.bin of a P4 program into a
+SetForwardingPipelineConfig request. The --action is one of bind, verify,
+verify_and_warm_init_begin, verify_and_warm_init_begin_and_end, warm_init_end
+and reconcile_and_warm_init_end. The warm_init_end action needs no program.
+
+  bfcli pipeline push --name tna_l3 --bfrt bf-rt.json --context context.json --binary tofino.bin
+  bfcli pipeline push --name tna_l3 --bfrt bf-rt.json --context context.json --binary tofino.bin --action verify`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cli, ctx, conn, cancel := dialServer()
+		defer conn.Close()
+		defer cancel()
+
+		action, err := parsePipelineAction(pipelineAction)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		if action == p4.SetForwardingPipelineConfigRequest_WARM_INIT_END {
+			if err := pushPipeline(cli, ctx, action, nil); err != nil {
+				fatalf("Got error when ending warm init: %v", err)
+			}
+			fmt.Println("Warm init ends")
+			return
+		}
+		config, err := loadPipelineConfig()
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		if err := pushPipeline(cli, ctx, action, config); err != nil {
+			fatalf("Got error when pushing pipeline: %v", err)
+		}
+		fmt.Printf("The program %s is pushed with %s\n", config.P4Name, strings.ToLower(action.String()))
+		if shellSession != nil {
+			if err := reloadPipelineInfo(cli, ctx); err != nil {
+				fatalf("Got error when reloading the pipeline: %v", err)
+			}
+		}
+	},
+}
+
+var pipelineShowCmd = &cobra.Command{
+	Use:   "show",
+	Args:  cobra.ExactArgs(0),
+	Short: "Show the program loaded on the device",
+	Run: func(cmd *cobra.Command, args []string) {
+		cli, ctx, conn, cancel := dialServer()
+		defer conn.Close()
+		defer cancel()
+
+		rsp, err := cli.GetForwardingPipelineConfig(ctx, &p4.GetForwardingPipelineConfigRequest{DeviceId: DEVICE_ID})
+		if err != nil {
+			fatalf("Got error when getting pipeline: %v", err)
+		}
+		if len(rsp.Config) == 0 {
+			fmt.Println("No program is loaded")
+		}
+		for _, c := range rsp.Config {
+			fmt.Printf("%-12s: %s\n", "Program", c.P4Name)
+			fmt.Printf("%-12s: %s\n", "BfRt Info", describeBfrtInfo(c.BfruntimeInfo))
+			for _, p := range c.Profiles {
+				fmt.Printf("%-12s: %s\n", "Profile", p.ProfileName)
+				fmt.Printf("%-12s: %v\n", "  Pipes", p.PipeScope)
+				fmt.Printf("%-12s: %d bytes\n", "  Context", len(p.Context))
+				fmt.Printf("%-12s: %d bytes, sha256 %x\n", "  Binary", len(p.Binary), sha256.Sum256(p.Binary))
+			}
+			fmt.Printf("------------------\n")
+		}
+		if rsp.NonP4Config != nil {
+			fmt.Printf("%-12s: %s\n", "Non-P4 Info", describeBfrtInfo(rsp.NonP4Config.BfruntimeInfo))
+		}
+	},
+}
+
+// parsePipelineAction parses the action of SetForwardingPipelineConfig by its
+// name in any case.
+func parsePipelineAction(name string) (p4.SetForwardingPipelineConfigRequest_Action, error) {
+	v, ok := p4.SetForwardingPipelineConfigRequest_Action_value[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown pipeline action %s", name)
+	}
+	return p4.SetForwardingPipelineConfigRequest_Action(v), nil
+}
+
+// loadPipelineConfig reads the compiler outputs given by the push flags.
+func loadPipelineConfig() (*p4.ForwardingPipelineConfig, error) {
+	if pipelineName == "" {
+		return nil, fmt.Errorf("please specify the program name by --name")
+	}
+	files := map[string][]byte{}
+	for _, f := range []string{pipelineBfrt, pipelineContext, pipelineBinary} {
+		if f == "" {
+			return nil, fmt.Errorf("please specify --bfrt, --context and --binary")
+		}
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		files[f] = b
+	}
+	pipes := make([]uint32, 0, len(pipelinePipes))
+	for _, p := range pipelinePipes {
+		pipes = append(pipes, uint32(p))
+	}
+	return &p4.ForwardingPipelineConfig{
+		P4Name:        pipelineName,
+		BfruntimeInfo: files[pipelineBfrt],
+		Profiles: []*p4.ForwardingPipelineConfig_Profile{
+			{
+				ProfileName: pipelineProfile,
+				Context:     files[pipelineContext],
+				Binary:      files[pipelineBinary],
+				PipeScope:   pipes,
+			},
+		},
+	}, nil
+}
+
+// pushPipeline subscribes as the master client of the device, which the
+// server requires for setting the pipeline, then sends the config.
+func pushPipeline(cli p4.BfRuntimeClient, ctx context.Context, action p4.SetForwardingPipelineConfigRequest_Action, config *p4.ForwardingPipelineConfig) error {
+	streamCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	stream, err := cli.StreamChannel(streamCtx)
+	if err != nil {
+		return err
+	}
+	err = stream.Send(&p4.StreamMessageRequest{
+		ClientId: pipelineClientId,
+		Update: &p4.StreamMessageRequest_Subscribe{
+			Subscribe: &p4.Subscribe{IsMaster: true, DeviceId: DEVICE_ID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := stream.Recv(); err != nil {
+		return fmt.Errorf("subscribe: %v", err)
+	}
+
+	req := &p4.SetForwardingPipelineConfigRequest{
+		ClientId: pipelineClientId,
+		DeviceId: DEVICE_ID,
+		Action:   action,
+		BasePath: pipelineBasePath,
+	}
+	if config != nil {
+		req.Config = []*p4.ForwardingPipelineConfig{config}
+	}
+	_, err = cli.SetForwardingPipelineConfig(ctx, req)
+	return err
+}
+
+// reloadPipelineInfo decodes the program on the device again, so the commands
+// following a push in the shell use the IDs of the new program.
+func reloadPipelineInfo(cli p4.BfRuntimeClient, ctx context.Context) error {
+	rsp, err := cli.GetForwardingPipelineConfig(ctx, &p4.GetForwardingPipelineConfigRequest{DeviceId: DEVICE_ID})
+	if err != nil {
+		return err
+	}
+	if len(rsp.Config) == 0 || rsp.NonP4Config == nil {
+		return fmt.Errorf("the device has no program")
+	}
+	var info, nonP4 util.BfRtInfoStruct
+	if err := gob.NewDecoder(bytes.NewReader(rsp.Config[0].BfruntimeInfo)).Decode(&info); err != nil {
+		return err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(rsp.NonP4Config.BfruntimeInfo)).Decode(&nonP4); err != nil {
+		return err
+	}
+	p4Info, nonP4Info = info, nonP4
+	resetPortMap()
+	return nil
+}
+
+// describeBfrtInfo summarises a BfRt info by its size and number of tables.
+func describeBfrtInfo(b []byte) string {
+	var info util.BfRtInfoStruct
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&info); err != nil {
+		return fmt.Sprintf("%d bytes", len(b))
+	}
+	return fmt.Sprintf("%d bytes, %d tables", len(b), len(info.Tables))
+}
+
+func init() {
+	rootCmd.AddCommand(pipelineCmd)
+	pipelineCmd.AddCommand(pipelinePushCmd)
+	pipelineCmd.AddCommand(pipelineShowCmd)
+
+	pipelinePushCmd.Flags().StringVar(&pipelineName, "name", "", "The name of P4 program")
+	pipelinePushCmd.Flags().StringVar(&pipelineBfrt, "bfrt", "", "The bf-rt.json of the program")
+	pipelinePushCmd.Flags().StringVar(&pipelineContext, "context", "", "The context.json of the program")
+	pipelinePushCmd.Flags().StringVar(&pipelineBinary, "binary", "", "The tofino.bin of the program")
+	pipelinePushCmd.Flags().StringVar(&pipelineProfile, "profile", "pipe", "The name of pipeline profile")
+	pipelinePushCmd.Flags().UintSliceVar(&pipelinePipes, "pipes", []uint{0, 1, 2, 3}, "The pipes the profile is applied to")
+	pipelinePushCmd.Flags().StringVar(&pipelineAction, "action", DEFAULT_PIPELINE_ACTION, "The action of pushing")
+	pipelinePushCmd.Flags().StringVar(&pipelineBasePath, "base-path", "", "The path on the server where the config is saved")
+	pipelinePushCmd.Flags().Uint32Var(&pipelineClientId, "client-id", 0, "The client ID subscribing to the device")
+	registerFlagCompletion(pipelinePushCmd, "action", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var actions []string
+		for name := range p4.SetForwardingPipelineConfigRequest_Action_value {
+			actions = append(actions, strings.ToLower(name))
+		}
+		return actions, cobra.ShellCompDirectiveNoFileComp
+	})
+}
@@ -0,0 +1,462 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"sort"
+	"strings"
+)
+
+// The types of tables carried over by pipeline upgrade in the order of
+// replay, so the action profile members and selector groups exist before the
+// flows referencing them.
+var replayTableTypes = []string{
+	METER_TABLE_TYPE,
+	PROFILE_TABLE_TYPE,
+	SELECTOR_TABLE_TYPE,
+	"MatchAction_Direct",
+	"MatchAction_Indirect",
+	"MatchAction_Indirect_Selector",
+}
+
+// The multicast tables of the device which are lost by an upgrade.
+var upgradeLostTables = []string{PRE_MGID_TABLE, PRE_NODE_TABLE}
+
+// warmInitHint tells how to recover a device left in warm init.
+const warmInitHint = "The device is left in warm init, end it with: bfcli pipeline push --action warm_init_end"
+
+var (
+	upgradeBatch        int
+	upgradeKeepWarmInit bool
+)
+
+// snapshotTable is the flows of a table read before an upgrade.
+type snapshotTable struct {
+	table   *util.Table
+	entries []*p4.TableEntry
+}
+
+// upgradeReport counts what could not be carried over to the new program,
+// by message.
+type upgradeReport map[string]int
+
+// pipelineUpgradeCmd represents the pipeline upgrade command
+var pipelineUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Args:  cobra.ExactArgs(0),
+	Short: "Upgrade the P4 program with warm init and keep the flows",
+	Long: `Upgrade the P4 program without losing the flows:
+
+  1. read the meters and the flows of the action profiles, selectors and
+     match tables
+  2. push the new program with verify_and_warm_init_begin
+  3. replay them onto the new schema, matching tables, fields and actions by
+     name and reporting the ones which no longer exist
+  4. push warm_init_end, unless --keep-warm-init is given
+
+Counters, TTL and hit state of the flows, register values, the entries of
+the other tables such as port metadata, LPF, WRED and indirect counters, and
+the multicast groups and nodes of $pre.mgid and $pre.node are not carried
+over, they are listed in the report instead.
+
+  bfcli pipeline upgrade --name tna_l3 --bfrt bf-rt.json --context context.json --binary tofino.bin`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, oldInfo, nonP4 := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		if upgradeBatch <= 0 {
+			fmt.Println("The --batch must be positive")
+			exit(1)
+		}
+		config, err := loadPipelineConfig()
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		old := *oldInfo
+		report := upgradeReport{}
+		snapshot, err := snapshotTables(cli, ctx, &old, report)
+		if err != nil {
+			fatalf("Got error when reading flows: %v", err)
+		}
+		total := 0
+		for _, s := range snapshot {
+			total += len(s.entries)
+		}
+		fmt.Printf("Read %d flows of %d tables\n", total, len(snapshot))
+		reportLostTables(cli, ctx, &old, nonP4, report)
+
+		err = pushPipeline(cli, ctx, p4.SetForwardingPipelineConfigRequest_VERIFY_AND_WARM_INIT_BEGIN, config)
+		if err != nil {
+			fatalf("Got error when pushing pipeline: %v", err)
+		}
+		fmt.Printf("The program %s is pushed, warm init begins\n", config.P4Name)
+
+		// The device stays in warm init on any failure from here on.
+		abort := func(format string, v ...interface{}) {
+			fmt.Printf(format+"\n", v...)
+			fmt.Println(warmInitHint)
+			exit(1)
+		}
+		rsp, err := cli.GetForwardingPipelineConfig(ctx, &p4.GetForwardingPipelineConfigRequest{DeviceId: DEVICE_ID})
+		if err != nil {
+			abort("Got error when getting the new pipeline: %v", err)
+		}
+		if len(rsp.Config) == 0 {
+			abort("The device has no program after pushing %s", config.P4Name)
+		}
+		var newInfo util.BfRtInfoStruct
+		if err := gob.NewDecoder(bytes.NewReader(rsp.Config[0].BfruntimeInfo)).Decode(&newInfo); err != nil {
+			abort("Got error when decoding the new pipeline: %v", err)
+		}
+
+		failed := 0
+		for _, s := range snapshot {
+			failed += replayTable(cli, ctx, s, &newInfo, report)
+		}
+		if len(report) != 0 {
+			fmt.Println("Not carried over:")
+			messages := make([]string, 0, len(report))
+			for m := range report {
+				messages = append(messages, m)
+			}
+			sort.Strings(messages)
+			for _, m := range messages {
+				fmt.Printf("  %s (%d entries)\n", m, report[m])
+			}
+		}
+		p4Info = newInfo
+
+		if upgradeKeepWarmInit {
+			fmt.Println("Warm init is kept, end it with: bfcli pipeline push --action warm_init_end")
+		} else {
+			if err := pushPipeline(cli, ctx, p4.SetForwardingPipelineConfigRequest_WARM_INIT_END, nil); err != nil {
+				abort("Got error when ending warm init: %v", err)
+			}
+			fmt.Println("Warm init ends")
+		}
+		if failed != 0 {
+			exit(1)
+		}
+	},
+}
+
+// reportLostTables counts the register values and the multicast entries which
+// the upgrade does not carry over.
+func reportLostTables(cli p4.BfRuntimeClient, ctx context.Context, info, nonP4 *util.BfRtInfoStruct, report upgradeReport) {
+	for _, t := range info.Tables {
+		if t.TableType == REGISTER_TABLE_TYPE {
+			report[fmt.Sprintf("values of register %s", t.Name)] += int(t.Size)
+		}
+	}
+	for i := range nonP4.Tables {
+		table := &nonP4.Tables[i]
+		for _, name := range upgradeLostTables {
+			if table.Name != name {
+				continue
+			}
+			entities, err := tryReadEntities(cli, ctx, tableEntryEntity(table, nil, nil))
+			if err != nil {
+				report[fmt.Sprintf("entries of %s, which can not be read: %v", name, err)]++
+				continue
+			}
+			if len(entities) != 0 {
+				report[fmt.Sprintf("multicast entries of %s", name)] += len(entities)
+			}
+		}
+	}
+}
+
+// snapshotTables reads the flows, including the default flows of match
+// tables, of every table replayed by an upgrade. The entries of the other
+// tables, such as port metadata, LPF, WRED and indirect counters, are added
+// to the report. Registers are reported by reportLostTables.
+func snapshotTables(cli p4.BfRuntimeClient, ctx context.Context, info *util.BfRtInfoStruct, report upgradeReport) ([]snapshotTable, error) {
+	var snapshot []snapshotTable
+	for i := range info.Tables {
+		table := &info.Tables[i]
+		if replayRank(table) < 0 {
+			if table.TableType == REGISTER_TABLE_TYPE {
+				continue
+			}
+			entities, err := tryReadEntities(cli, ctx, tableEntryEntity(table, nil, nil))
+			if err != nil {
+				report[fmt.Sprintf("entries of %s, which can not be read: %v", table.Name, err)]++
+			} else if len(entities) != 0 {
+				report[fmt.Sprintf("entries of %s table %s", table.TableType, table.Name)] += len(entities)
+			}
+			continue
+		}
+		s := snapshotTable{table: table}
+		entities, err := tryReadEntities(cli, ctx, tableEntryEntity(table, nil, nil))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", table.Name, err)
+		}
+		if strings.HasPrefix(table.TableType, "MatchAction") {
+			def := tableEntryEntity(table, nil, nil)
+			def.GetTableEntry().IsDefaultEntry = true
+			if d, err := tryReadEntities(cli, ctx, def); err == nil {
+				for _, e := range d {
+					if tbl := e.GetTableEntry(); tbl != nil {
+						tbl.IsDefaultEntry = true
+					}
+				}
+				entities = append(entities, d...)
+			}
+		}
+		for _, e := range entities {
+			if tbl := e.GetTableEntry(); tbl != nil {
+				s.entries = append(s.entries, tbl)
+			}
+		}
+		snapshot = append(snapshot, s)
+	}
+	sort.SliceStable(snapshot, func(i, j int) bool {
+		return replayRank(snapshot[i].table) < replayRank(snapshot[j].table)
+	})
+	return snapshot, nil
+}
+
+func replayRank(table *util.Table) int {
+	for i, t := range replayTableTypes {
+		if table.TableType == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// replayTable writes the flows of a snapshot onto the table with the same name
+// in the new schema, and returns the number of flows failed to write.
+func replayTable(cli p4.BfRuntimeClient, ctx context.Context, s snapshotTable, newInfo *util.BfRtInfoStruct, report upgradeReport) int {
+	if len(s.entries) == 0 {
+		return 0
+	}
+	var table *util.Table
+	for i := range newInfo.Tables {
+		if newInfo.Tables[i].Name == s.table.Name {
+			table = &newInfo.Tables[i]
+		}
+	}
+	if table == nil {
+		report[fmt.Sprintf("table %s no longer exists", s.table.Name)] += len(s.entries)
+		return 0
+	}
+
+	var updates []*p4.Update
+	for _, e := range s.entries {
+		entry, ok := remapEntry(s.table, table, e, report)
+		if !ok {
+			continue
+		}
+		updateType := p4.Update_INSERT
+		// The default flows and the meters at every index always exist.
+		if entry.IsDefaultEntry || table.TableType == METER_TABLE_TYPE {
+			updateType = p4.Update_MODIFY
+		}
+		updates = append(updates, &p4.Update{
+			Type:   updateType,
+			Entity: &p4.Entity{Entity: &p4.Entity_TableEntry{TableEntry: entry}},
+		})
+	}
+
+	failed := 0
+	var firstErr error
+	for start := 0; start < len(updates); start += upgradeBatch {
+		end := start + upgradeBatch
+		if end > len(updates) {
+			end = len(updates)
+		}
+		if writeUpdates(cli, ctx, updates[start:end]...) == nil {
+			continue
+		}
+		// Write the flows of the failed batch one by one to find the bad ones.
+		for _, u := range updates[start:end] {
+			if err := writeUpdates(cli, ctx, u); err != nil {
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}
+	fmt.Printf("%-40s: %d/%d flows replayed\n", table.Name, len(updates)-failed, len(s.entries))
+	if firstErr != nil {
+		fmt.Printf("%-40s: %d flows failed, first error: %v\n", "", failed, firstErr)
+	}
+	return failed
+}
+
+// remapEntry translates the IDs of a flow from the old table to the new one
+// by names, and resizes the values to the new widths. The flow is dropped
+// when a key field or its action no longer exists, missing data fields are
+// left out.
+func remapEntry(oldTable, table *util.Table, e *p4.TableEntry, report upgradeReport) (*p4.TableEntry, bool) {
+	entry := &p4.TableEntry{TableId: table.ID, IsDefaultEntry: e.IsDefaultEntry}
+
+	if e.GetKey() != nil {
+		entry.Key = &p4.TableKey{}
+		for _, f := range e.GetKey().GetFields() {
+			old, ok := searchFieldById(keyFields(oldTable), f.FieldId)
+			if !ok {
+				report[fmt.Sprintf("key field %d of %s is unknown", f.FieldId, oldTable.Name)]++
+				return nil, false
+			}
+			field, ok := searchFieldByName(keyFields(table), old.Name)
+			if !ok {
+				report[fmt.Sprintf("key field %s of %s no longer exists", old.Name, table.Name)]++
+				return nil, false
+			}
+			keyField, err := resizeKeyField(f, field)
+			if err != nil {
+				report[fmt.Sprintf("key field %s of %s: %v", old.Name, table.Name, err)]++
+				return nil, false
+			}
+			entry.Key.Fields = append(entry.Key.Fields, keyField)
+		}
+	}
+
+	data := e.GetData()
+	if data == nil {
+		return entry, true
+	}
+	entry.Data = &p4.TableData{}
+	var params []fieldInfo
+	if data.GetActionId() != 0 {
+		old, ok := searchActionById(oldTable, data.GetActionId())
+		if !ok {
+			report[fmt.Sprintf("action %d of %s is unknown", data.GetActionId(), oldTable.Name)]++
+			return nil, false
+		}
+		var action actionInfo
+		for _, a := range actionSpecs(table) {
+			if a.Name == old.Name {
+				action = a
+			}
+		}
+		if action.Name == "" {
+			report[fmt.Sprintf("action %s of %s no longer exists", old.Name, table.Name)]++
+			return nil, false
+		}
+		entry.Data.ActionId = action.ID
+		params = action.Params
+	}
+	for _, d := range data.GetFields() {
+		name := dataFieldName(oldTable, data.GetActionId(), d.FieldId)
+		if isVolatileField(name) {
+			continue
+		}
+		field, ok := searchFieldByName(params, name)
+		if !ok {
+			field, ok = searchFieldByName(dataFields(table), name)
+		}
+		if !ok {
+			report[fmt.Sprintf("data field %s of %s no longer exists", name, table.Name)]++
+			continue
+		}
+		dataField := &p4.DataField{FieldId: field.ID, Value: d.GetValue()}
+		if s, ok := d.GetValue().(*p4.DataField_Stream); ok {
+			stream, err := resizeValue(s.Stream, field)
+			if err != nil {
+				report[fmt.Sprintf("data field %s of %s: %v", name, table.Name, err)]++
+				return nil, false
+			}
+			dataField.Value = &p4.DataField_Stream{Stream: stream}
+		}
+		entry.Data.Fields = append(entry.Data.Fields, dataField)
+	}
+	return entry, true
+}
+
+// searchFieldByName is searchField without the suffix match, as the names
+// of both schemas are full names.
+func searchFieldByName(fields []fieldInfo, name string) (fieldInfo, bool) {
+	for _, f := range fields {
+		if f.Name == name {
+			return f, FOUND
+		}
+	}
+	return fieldInfo{}, NOT_FOUND
+}
+
+func resizeKeyField(f *p4.KeyField, field fieldInfo) (*p4.KeyField, error) {
+	keyField := &p4.KeyField{FieldId: field.ID}
+	var err error
+	switch m := f.GetMatchType().(type) {
+	case *p4.KeyField_Exact_:
+		exact := &p4.KeyField_Exact{}
+		exact.Value, err = resizeValue(m.Exact.GetValue(), field)
+		keyField.MatchType = &p4.KeyField_Exact_{Exact: exact}
+	case *p4.KeyField_Ternary_:
+		ternary := &p4.KeyField_Ternary{}
+		if ternary.Value, err = resizeValue(m.Ternary.GetValue(), field); err == nil {
+			ternary.Mask, err = resizeValue(m.Ternary.GetMask(), field)
+		}
+		keyField.MatchType = &p4.KeyField_Ternary_{Ternary: ternary}
+	case *p4.KeyField_Lpm:
+		lpm := &p4.KeyField_LPM{PrefixLen: m.Lpm.GetPrefixLen()}
+		lpm.Value, err = resizeValue(m.Lpm.GetValue(), field)
+		keyField.MatchType = &p4.KeyField_Lpm{Lpm: lpm}
+	case *p4.KeyField_Range_:
+		r := &p4.KeyField_Range{}
+		if r.Low, err = resizeValue(m.Range.GetLow(), field); err == nil {
+			r.High, err = resizeValue(m.Range.GetHigh(), field)
+		}
+		keyField.MatchType = &p4.KeyField_Range_{Range: r}
+	default:
+		keyField.MatchType = f.GetMatchType()
+	}
+	return keyField, err
+}
+
+// resizeValue re-encodes a value in the width of the new field, failing when
+// it does not fit. Strings are kept as they are.
+func resizeValue(b []byte, field fieldInfo) ([]byte, error) {
+	if field.Type == "string" || field.bitWidth() <= 0 {
+		return b, nil
+	}
+	return encodeValue(decodeValue(b).String(), field.bitWidth())
+}
+
+func init() {
+	pipelineCmd.AddCommand(pipelineUpgradeCmd)
+
+	pipelineUpgradeCmd.Flags().StringVar(&pipelineName, "name", "", "The name of P4 program")
+	pipelineUpgradeCmd.Flags().StringVar(&pipelineBfrt, "bfrt", "", "The bf-rt.json of the program")
+	pipelineUpgradeCmd.Flags().StringVar(&pipelineContext, "context", "", "The context.json of the program")
+	pipelineUpgradeCmd.Flags().StringVar(&pipelineBinary, "binary", "", "The tofino.bin of the program")
+	pipelineUpgradeCmd.Flags().StringVar(&pipelineProfile, "profile", "pipe", "The name of pipeline profile")
+	pipelineUpgradeCmd.Flags().UintSliceVar(&pipelinePipes, "pipes", []uint{0, 1, 2, 3}, "The pipes the profile is applied to")
+	pipelineUpgradeCmd.Flags().StringVar(&pipelineBasePath, "base-path", "", "The path on the server where the config is saved")
+	pipelineUpgradeCmd.Flags().Uint32Var(&pipelineClientId, "client-id", 0, "The client ID subscribing to the device")
+	pipelineUpgradeCmd.Flags().IntVar(&upgradeBatch, "batch", 500, "The number of flows in a Write RPC when replaying")
+	pipelineUpgradeCmd.Flags().BoolVar(&upgradeKeepWarmInit, "keep-warm-init", false, "Do not end warm init after replaying")
+	pipelineUpgradeCmd.MarkFlagRequired("name")
+	pipelineUpgradeCmd.MarkFlagRequired("bfrt")
+	pipelineUpgradeCmd.MarkFlagRequired("context")
+	pipelineUpgradeCmd.MarkFlagRequired("binary")
+}
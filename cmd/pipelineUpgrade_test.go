@@ -0,0 +1,108 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"github.com/P4Networking/proto/go/p4"
+	"testing"
+)
+
+func TestResizeValue(t *testing.T) {
+	tests := []struct {
+		b       []byte
+		field   fieldInfo
+		want    []byte
+		wantErr bool
+	}{
+		{[]byte{0, 5}, fieldInfo{Type: "bytes", Width: 32}, []byte{0, 0, 0, 5}, false},
+		{[]byte{0, 0, 1, 0xff}, fieldInfo{Type: "bytes", Width: 9}, []byte{1, 0xff}, false},
+		{[]byte{2, 0}, fieldInfo{Type: "bytes", Width: 9}, nil, true},
+		{[]byte("3/0"), fieldInfo{Type: "string"}, []byte("3/0"), false},
+		{[]byte{7}, fieldInfo{Type: "bytes"}, []byte{7}, false},
+	}
+	for _, tt := range tests {
+		got, err := resizeValue(tt.b, tt.field)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resizeValue(%x, %d) error = %v, want error %t", tt.b, tt.field.Width, err, tt.wantErr)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("resizeValue(%x, %d) = %x, want %x", tt.b, tt.field.Width, got, tt.want)
+		}
+	}
+}
+
+func TestRemapEntry(t *testing.T) {
+	oldTable := testTable(t, testLpmTable)
+	// The new schema renumbers the fields and actions, widens the port and
+	// drops the vrf key and the drop action.
+	newTable := testTable(t, `{
+		"name": "SwitchIngress.ipv4_lpm", "id": 7, "table_type": "MatchAction_Direct",
+		"key": [
+			{"id": 5, "name": "hdr.ipv4.dst_addr", "match_type": "LPM", "mandatory": true, "type": {"type": "bytes", "width": 32}}
+		],
+		"action_specs": [
+			{"id": 20, "name": "SwitchIngress.set_nexthop", "data": [
+				{"id": 3, "name": "port", "mandatory": true, "type": {"type": "bytes", "width": 16}}
+			]}
+		]
+	}`)
+	dst := &p4.KeyField{FieldId: 1, MatchType: &p4.KeyField_Lpm{Lpm: &p4.KeyField_LPM{Value: []byte{10, 0, 0, 0}, PrefixLen: 8}}}
+	vrf := &p4.KeyField{FieldId: 2, MatchType: &p4.KeyField_Exact_{Exact: &p4.KeyField_Exact{Value: []byte{1}}}}
+	nexthop := &p4.TableData{ActionId: 10, Fields: []*p4.DataField{
+		{FieldId: 1, Value: &p4.DataField_Stream{Stream: []byte{1, 0x10}}},
+		{FieldId: 2, Value: &p4.DataField_Stream{Stream: []byte{0, 0, 0, 0, 0, 1}}},
+		{FieldId: 65553, Value: &p4.DataField_Stream{Stream: []byte{9}}},
+	}}
+	tests := []struct {
+		name    string
+		entry   *p4.TableEntry
+		ok      bool
+		key     string
+		params  int
+		reports int
+	}{
+		{"remapped", &p4.TableEntry{Key: &p4.TableKey{Fields: []*p4.KeyField{dst}}, Data: nexthop}, true, "lpm:0a000000/8", 1, 1},
+		{"key field removed", &p4.TableEntry{Key: &p4.TableKey{Fields: []*p4.KeyField{dst, vrf}}, Data: nexthop}, false, "", 0, 1},
+		{"key field unknown", &p4.TableEntry{Key: &p4.TableKey{Fields: []*p4.KeyField{{FieldId: 9}}}, Data: nexthop}, false, "", 0, 1},
+		{"action removed", &p4.TableEntry{Key: &p4.TableKey{Fields: []*p4.KeyField{dst}}, Data: &p4.TableData{ActionId: 11}}, false, "", 0, 1},
+		{"default flow", &p4.TableEntry{IsDefaultEntry: true, Data: &p4.TableData{ActionId: 10}}, true, "", 0, 0},
+	}
+	for _, tt := range tests {
+		report := upgradeReport{}
+		entry, ok := remapEntry(oldTable, newTable, tt.entry, report)
+		if ok != tt.ok || len(report) != tt.reports {
+			t.Errorf("%s: remapEntry = %t with report %v", tt.name, ok, report)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if entry.TableId != 7 || entry.IsDefaultEntry != tt.entry.IsDefaultEntry {
+			t.Errorf("%s: remapEntry gives table %d, default %t", tt.name, entry.TableId, entry.IsDefaultEntry)
+		}
+		if tt.key != "" && describeKeyField(entry.Key.Fields[0]) != tt.key {
+			t.Errorf("%s: remapEntry gives key %s, want %s", tt.name, describeKeyField(entry.Key.Fields[0]), tt.key)
+		}
+		if entry.Data.ActionId != 20 || len(entry.Data.Fields) != tt.params {
+			t.Errorf("%s: remapEntry gives action %d with %d params", tt.name, entry.Data.ActionId, len(entry.Data.Fields))
+		}
+		if tt.params != 0 && !bytes.Equal(entry.Data.Fields[0].GetStream(), []byte{1, 0x10}) {
+			t.Errorf("%s: remapEntry gives port %x", tt.name, entry.Data.Fields[0].GetStream())
+		}
+	}
+}
@@ -0,0 +1,357 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	PORT_TABLE          = "$PORT"
+	PORT_STR_INFO_TABLE = "$PORT_STR_INFO"
+	PORT_NAME           = "$PORT_NAME"
+	PORT_SPEED          = "$SPEED"
+	PORT_FEC            = "$FEC"
+	PORT_AN             = "$AUTO_NEGOTIATION"
+	PORT_ENABLE         = "$PORT_ENABLE"
+	PORT_UP             = "$PORT_UP"
+	PORT_RX_MTU         = "$RX_MTU"
+	PORT_TX_MTU         = "$TX_MTU"
+)
+
+var (
+	portSpeed  string
+	portFec    string
+	portAn     string
+	portMtu    uint32
+	portEnable bool
+
+	portFecs = map[string]string{
+		"none":     "BF_FEC_TYP_NONE",
+		"fc":       "BF_FEC_TYP_FIRECODE",
+		"firecode": "BF_FEC_TYP_FIRECODE",
+		"rs":       "BF_FEC_TYP_REED_SOLOMON",
+	}
+	portAns = map[string]string{
+		"default": "PM_AN_DEFAULT",
+		"on":      "PM_AN_FORCE_ENABLE",
+		"off":     "PM_AN_FORCE_DISABLE",
+	}
+)
+
+// portCmd represents the port command
+var portCmd = &cobra.Command{
+	Use:   "port",
+	Short: "Manage the ports of device",
+	Long: `Manage the ports of device through the $PORT table. Ports are given by
+front-panel name such as 1/0, which is translated to device port through the
+$PORT_STR_INFO table, or by device port number.`,
+}
+
+var portAddCmd = &cobra.Command{
+	Use:   "add PORT",
+	Args:  cobra.ExactArgs(1),
+	Short: "Add a port",
+	Long: `Add a port with speed, FEC and auto-negotiation.
+
+  bfcli port add 1/0 --speed 100G --fec rs --an off`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table, key := portTarget(args[0])
+		if table == nil {
+			exit(1)
+		}
+
+		speed := strings.ToUpper(portSpeed)
+		if !strings.HasPrefix(speed, "BF_SPEED_") {
+			speed = "BF_SPEED_" + speed
+		}
+		fec, ok := portFecs[portFec]
+		if !ok {
+			fmt.Printf("Invalid FEC %s, expect none, fc or rs\n", portFec)
+			exit(1)
+		}
+		assignments := []string{PORT_SPEED + "=" + speed, PORT_FEC + "=" + fec}
+		if cmd.Flags().Changed("an") {
+			an, ok := portAns[portAn]
+			if !ok {
+				fmt.Printf("Invalid auto-negotiation %s, expect default, on or off\n", portAn)
+				exit(1)
+			}
+			assignments = append(assignments, PORT_AN+"="+an)
+		}
+		if cmd.Flags().Changed("mtu") {
+			assignments = append(assignments,
+				fmt.Sprintf("%s=%d", PORT_RX_MTU, portMtu),
+				fmt.Sprintf("%s=%d", PORT_TX_MTU, portMtu))
+		}
+		if portEnable {
+			assignments = append(assignments, PORT_ENABLE+"=true")
+		}
+		data, err := buildTableData(table, assignments)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		err = writeUpdates(cli, ctx, &p4.Update{
+			Type:   p4.Update_INSERT,
+			Entity: tableEntryEntity(table, key, data),
+		})
+		if err != nil {
+			fatalf("Got error when adding port: %v", err)
+		}
+		resetPortMap()
+		fmt.Printf("Port %s is added\n", args[0])
+	},
+}
+
+var portDelCmd = &cobra.Command{
+	Use:   "del PORT",
+	Args:  cobra.ExactArgs(1),
+	Short: "Delete a port",
+	Long:  `Delete a port`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table, key := portTarget(args[0])
+		if table == nil {
+			exit(1)
+		}
+		err := writeUpdates(cli, ctx, &p4.Update{
+			Type:   p4.Update_DELETE,
+			Entity: tableEntryEntity(table, key, nil),
+		})
+		if err != nil {
+			fatalf("Got error when deleting port: %v", err)
+		}
+		resetPortMap()
+		fmt.Printf("Port %s is deleted\n", args[0])
+	},
+}
+
+var portEnableCmd = &cobra.Command{
+	Use:   "enable PORT...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Enable ports",
+	Long:  `Set the admin state of ports to enabled`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setPortEnable(args, true)
+	},
+}
+
+var portDisableCmd = &cobra.Command{
+	Use:   "disable PORT...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Disable ports",
+	Long:  `Set the admin state of ports to disabled`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setPortEnable(args, false)
+	},
+}
+
+var portShowCmd = &cobra.Command{
+	Use:   "show [PORT...]",
+	Short: "Show ports",
+	Long:  `Show speed, FEC, auto-negotiation, admin and oper state and MTU of ports`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findNonP4Table(PORT_TABLE)
+		if table == nil {
+			exit(1)
+		}
+		var entities []*p4.Entity
+		for _, name := range args {
+			_, key := portTarget(name)
+			if key == nil {
+				exit(1)
+			}
+			entities = append(entities, tableEntryEntity(table, key, nil))
+		}
+		if len(entities) == 0 {
+			entities = append(entities, tableEntryEntity(table, nil, nil))
+		}
+
+		devField, _ := searchField(keyFields(table), DEV_PORT)
+		type portRow struct {
+			dev  uint64
+			cols []string
+		}
+		var rows []portRow
+		for _, e := range readEntities(cli, ctx, entities...) {
+			tbl := e.GetTableEntry()
+			if tbl == nil {
+				continue
+			}
+			var dev uint64
+			for _, f := range tbl.GetKey().GetFields() {
+				if f.FieldId == devField.ID {
+					dev = decodeValue(f.GetExact().GetValue()).Uint64()
+				}
+			}
+			fields := tbl.GetData().GetFields()
+			rows = append(rows, portRow{dev: dev, cols: []string{
+				portDataValue(table, fields, PORT_NAME),
+				strconv.FormatUint(dev, 10),
+				strings.TrimPrefix(portDataValue(table, fields, PORT_SPEED), "BF_SPEED_"),
+				strings.TrimPrefix(portDataValue(table, fields, PORT_FEC), "BF_FEC_TYP_"),
+				strings.TrimPrefix(portDataValue(table, fields, PORT_AN), "PM_AN_"),
+				upDown(portDataValue(table, fields, PORT_ENABLE)),
+				upDown(portDataValue(table, fields, PORT_UP)),
+				portDataValue(table, fields, PORT_RX_MTU),
+			}})
+		}
+		if len(rows) == 0 {
+			fmt.Println("The ports is null")
+			return
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			return rows[i].dev < rows[j].dev
+		})
+
+		format := "%-8s %-8s %-8s %-14s %-14s %-6s %-6s %-6s\n"
+		fmt.Printf(format, "Port", "DevPort", "Speed", "FEC", "AN", "Admin", "Oper", "MTU")
+		for _, r := range rows {
+			cols := make([]interface{}, len(r.cols))
+			for i, c := range r.cols {
+				cols[i] = c
+			}
+			fmt.Printf(format, cols...)
+		}
+	},
+}
+
+// portTarget resolves a front-panel name or device port number to the key of
+// the $PORT table, it prints the reason and returns nil on failure.
+func portTarget(name string) (*util.Table, *p4.TableKey) {
+	table := findNonP4Table(PORT_TABLE)
+	if table == nil {
+		return nil, nil
+	}
+	dev, err := portDevice(name)
+	if err != nil {
+		fmt.Println(err)
+		return nil, nil
+	}
+	key, err := buildTableKey(table, []string{fmt.Sprintf("%s=%d", DEV_PORT, dev)})
+	if err != nil {
+		fmt.Println(err)
+		return nil, nil
+	}
+	return table, key
+}
+
+// portDevice translates a front-panel name such as 1/0 to device port
+// through the port map of device, device port numbers are kept as is.
+func portDevice(name string) (uint32, error) {
+	if n, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+	return devicePortMap().device(name)
+}
+
+func setPortEnable(names []string, enable bool) {
+	cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+	defer conn.Close()
+	defer cancel()
+	cli := *cliAddr
+	ctx := *ctxAddr
+
+	for _, name := range names {
+		table, key := portTarget(name)
+		if table == nil {
+			exit(1)
+		}
+		data, err := buildTableData(table, []string{fmt.Sprintf("%s=%t", PORT_ENABLE, enable)})
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		err = writeUpdates(cli, ctx, &p4.Update{
+			Type:   p4.Update_MODIFY,
+			Entity: tableEntryEntity(table, key, data),
+		})
+		if err != nil {
+			fatalf("Got error when modifying port %s: %v", name, err)
+		}
+		fmt.Printf("Port %s is %s\n", name, map[bool]string{true: "enabled", false: "disabled"}[enable])
+	}
+}
+
+// portDataValue renders a data field of the $PORT table by name, integers
+// in decimal, or "-" when the field is absent.
+func portDataValue(table *util.Table, fields []*p4.DataField, name string) string {
+	field, ok := searchField(dataFields(table), name)
+	if !ok {
+		return "-"
+	}
+	for _, d := range fields {
+		if d.FieldId != field.ID {
+			continue
+		}
+		if _, ok := d.GetValue().(*p4.DataField_Stream); ok {
+			return decodeValue(d.GetStream()).String()
+		}
+		return formatDataField(d)
+	}
+	return "-"
+}
+
+func upDown(v string) string {
+	switch v {
+	case "true":
+		return "up"
+	case "false":
+		return "down"
+	}
+	return v
+}
+
+func init() {
+	rootCmd.AddCommand(portCmd)
+	portCmd.AddCommand(portAddCmd)
+	portCmd.AddCommand(portDelCmd)
+	portCmd.AddCommand(portEnableCmd)
+	portCmd.AddCommand(portDisableCmd)
+	portCmd.AddCommand(portShowCmd)
+
+	portAddCmd.Flags().StringVar(&portSpeed, "speed", "", "The speed of port, e.g. 10G, 25G, 100G")
+	portAddCmd.Flags().StringVar(&portFec, "fec", "none", "The FEC of port, none, fc or rs")
+	portAddCmd.Flags().StringVar(&portAn, "an", "default", "The auto-negotiation of port, default, on or off")
+	portAddCmd.Flags().Uint32Var(&portMtu, "mtu", 0, "The MTU of port")
+	portAddCmd.Flags().BoolVar(&portEnable, "enable", false, "Enable the port after adding")
+	portAddCmd.MarkFlagRequired("speed")
+}
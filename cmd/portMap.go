@@ -0,0 +1,192 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"strconv"
+	"strings"
+)
+
+// The names of key fields and action parameters holding device ports, which
+// can be overridden by "port-fields" in the config file.
+var defaultPortFields = []string{"port", "dev_port", "ingress_port", "egress_port", "egress_spec", "ucast_egress_port"}
+
+// portMap translates between front-panel names such as 3/0 and device ports.
+// Names are looked up one by one, since $PORT_STR_INFO only supports keyed
+// reads, while the names of all device ports come from $PORT at once.
+type portMap struct {
+	byName   map[string]uint32
+	byDev    map[uint32]string
+	namesErr error
+}
+
+// portMaps caches the port map of each device by server address.
+var portMaps = map[string]*portMap{}
+
+// resetPortMap drops the cached port map of the device, after the ports or the
+// program on it change.
+func resetPortMap() {
+	delete(portMaps, server)
+}
+
+// devicePortMap returns the port map of the connected device. Translations are
+// only cached once they are read successfully.
+func devicePortMap() *portMap {
+	m, ok := portMaps[server]
+	if !ok {
+		m = &portMap{byName: map[string]uint32{}}
+		portMaps[server] = m
+	}
+	return m
+}
+
+// device translates a front-panel name to the device port by a keyed read of
+// $PORT_STR_INFO.
+func (m *portMap) device(name string) (uint32, error) {
+	if dev, ok := m.byName[name]; ok {
+		return dev, nil
+	}
+	table := findTable(PORT_STR_INFO_TABLE)
+	if table == nil {
+		return 0, fmt.Errorf("the device does not provide the table %s", PORT_STR_INFO_TABLE)
+	}
+	if bfrtClient == nil {
+		return 0, fmt.Errorf("can not found port %s without connection", name)
+	}
+	key, err := buildTableKey(table, []string{PORT_NAME + "=" + name})
+	if err != nil {
+		return 0, err
+	}
+	devField, _ := searchField(dataFields(table), DEV_PORT)
+	entities, err := tryReadEntities(bfrtClient, bfrtCtx, tableEntryEntity(table, key, nil))
+	if err != nil {
+		return 0, fmt.Errorf("can not found port %s: %v", name, err)
+	}
+	for _, e := range entities {
+		if v := collectDataValues(e.GetTableEntry().GetData().GetFields(), devField.ID); len(v) != 0 {
+			dev := uint32(v[0].Uint64())
+			m.byName[name] = dev
+			return dev, nil
+		}
+	}
+	return 0, fmt.Errorf("can not found port %s", name)
+}
+
+// names reads the front-panel name of every device port from the $PORT_NAME
+// data field of $PORT. A failed read is kept as well, so formatting many
+// port values does not repeat it.
+func (m *portMap) names() (map[uint32]string, error) {
+	if m.byDev != nil || m.namesErr != nil {
+		return m.byDev, m.namesErr
+	}
+	if bfrtClient == nil {
+		return nil, fmt.Errorf("can not read the port names without connection")
+	}
+	m.byDev, m.namesErr = readPortNames()
+	return m.byDev, m.namesErr
+}
+
+func readPortNames() (map[uint32]string, error) {
+	table := findTable(PORT_TABLE)
+	if table == nil {
+		return nil, fmt.Errorf("the device does not provide the table %s", PORT_TABLE)
+	}
+	devField, _ := searchField(keyFields(table), DEV_PORT)
+	nameField, ok := searchField(dataFields(table), PORT_NAME)
+	if !ok {
+		return nil, fmt.Errorf("can not found field %s in table %s", PORT_NAME, table.Name)
+	}
+	entities, err := tryReadEntities(bfrtClient, bfrtCtx, tableEntryEntity(table, nil, nil))
+	if err != nil {
+		return nil, fmt.Errorf("can not read the port names: %v", err)
+	}
+	byDev := map[uint32]string{}
+	for _, e := range entities {
+		tbl := e.GetTableEntry()
+		if tbl == nil {
+			continue
+		}
+		var dev uint32
+		for _, f := range tbl.GetKey().GetFields() {
+			if f.FieldId == devField.ID {
+				dev = uint32(decodeValue(f.GetExact().GetValue()).Uint64())
+			}
+		}
+		for _, d := range tbl.GetData().GetFields() {
+			if d.FieldId == nameField.ID && formatDataField(d) != "" {
+				byDev[dev] = formatDataField(d)
+			}
+		}
+	}
+	return byDev, nil
+}
+
+// portName returns the front-panel name of a device port, it is false when the
+// name is unknown or the names can not be read.
+func portName(dev uint32) (string, bool) {
+	names, err := devicePortMap().names()
+	if err != nil {
+		return "", false
+	}
+	name, ok := names[dev]
+	return name, ok
+}
+
+// isPortField reports whether a key field or action parameter holds a device
+// port, judged by the last dotted component of its name.
+func isPortField(name string) bool {
+	fields := defaultPortFields
+	if viper.IsSet("port-fields") {
+		fields = viper.GetStringSlice("port-fields")
+	}
+	name = name[strings.LastIndex(name, ".")+1:]
+	for _, f := range fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// translatePortValue turns a front-panel name into the device port number
+// for port fields, any other value is returned unchanged.
+func translatePortValue(field fieldInfo, value string) (string, error) {
+	if !isPortField(field.Name) || !strings.Contains(value, "/") {
+		return value, nil
+	}
+	dev, err := devicePortMap().device(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid port of %s: %v", field.Name, err)
+	}
+	return strconv.FormatUint(uint64(dev), 10), nil
+}
+
+// formatPortValue renders the value of a port field as its front-panel name
+// followed by the device port, such as "3/0 (dev 136)". The name alone is
+// accepted back by translatePortValue.
+func formatPortValue(name string, b []byte) (string, bool) {
+	if !isPortField(name) {
+		return "", false
+	}
+	dev := uint32(decodeValue(b).Uint64())
+	port, ok := portName(dev)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%s (dev %d)", port, dev), true
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"github.com/P4Networking/proto/go/p4"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// withPortMap caches a port map of 3/0 as device port 136 for the test.
+func withPortMap(t *testing.T) {
+	t.Helper()
+	portMaps[server] = &portMap{
+		byName: map[string]uint32{"3/0": 136},
+		byDev:  map[uint32]string{136: "3/0"},
+	}
+	t.Cleanup(func() {
+		delete(portMaps, server)
+	})
+}
+
+func TestTranslatePortValue(t *testing.T) {
+	withPortMap(t)
+	port := fieldInfo{Name: "hdr.ig_intr_md.ingress_port", Width: 9}
+	tests := []struct {
+		field   fieldInfo
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{port, "3/0", "136", false},
+		{port, "136", "136", false},
+		{fieldInfo{Name: "hdr.ipv4.dst_addr"}, "10.0.0.0/8", "10.0.0.0/8", false},
+		{port, "9/0", "", true},
+	}
+	for _, tt := range tests {
+		got, err := translatePortValue(tt.field, tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("translatePortValue(%s, %q) = %q, %v, want %q", tt.field.Name, tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormatKeyFieldPort(t *testing.T) {
+	withPortMap(t)
+	table := testTable(t, `{"name": "SwitchIngress.port_vlan", "key": [
+		{"id": 1, "name": "ingress_port", "match_type": "Exact", "mandatory": true, "type": {"type": "bytes", "width": 9}}
+	]}`)
+	f := &p4.KeyField{FieldId: 1, MatchType: &p4.KeyField_Exact_{Exact: &p4.KeyField_Exact{Value: []byte{0, 136}}}}
+	got := formatKeyField("ingress_port", f)
+	if got != "ingress_port=3/0 (dev 136)" {
+		t.Fatalf("formatKeyField = %q, want ingress_port=3/0 (dev 136)", got)
+	}
+
+	// The rendered name is accepted back by buildTableKey and --match.
+	got = strings.Fields(got)[0]
+	key, err := buildTableKey(table, []string{got})
+	if err != nil || describeKeyField(key.Fields[0]) != "exact:0088" {
+		t.Errorf("buildTableKey(%q) = %v, %v", got, key, err)
+	}
+	filters, err := parseMatchFilters(table, []string{got})
+	if err != nil || filters[0].value.Cmp(big.NewInt(136)) != 0 || filters[0].prefixLen != -1 {
+		t.Errorf("parseMatchFilters(%q) = %v, %v", got, filters, err)
+	}
+}
+
+func TestPortNamesKeepsFailure(t *testing.T) {
+	failure := errors.New("can not read the port names")
+	portMaps[server] = &portMap{byName: map[string]uint32{}, namesErr: failure}
+	t.Cleanup(func() {
+		delete(portMaps, server)
+	})
+	if _, err := devicePortMap().names(); err != failure {
+		t.Errorf("names() error = %v, want the kept failure", err)
+	}
+	if port, ok := formatPortValue("ingress_port", []byte{0, 136}); ok {
+		t.Errorf("formatPortValue = %q, want no name", port)
+	}
+
+	resetPortMap()
+	if m := devicePortMap(); m.namesErr != nil || m.byDev != nil {
+		t.Errorf("the port map is kept after resetPortMap")
+	}
+}
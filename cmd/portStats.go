@@ -0,0 +1,255 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"sort"
+	"time"
+)
+
+const PORT_STAT_TABLE = "$PORT_STAT"
+
+var (
+	portStatsWatch time.Duration
+	portStatsClear bool
+
+	// The well-known counters of $PORT_STAT shown by port stats.
+	portStatCounters = []struct {
+		label string
+		name  string
+	}{
+		{"RxPkts", "$FramesReceivedAll"},
+		{"RxBytes", "$OctetsReceived"},
+		{"RxErrs", "$FrameswithanyError"},
+		{"RxFCS", "$FramesReceivedwithFCSError"},
+		{"TxPkts", "$FramesTransmittedAll"},
+		{"TxBytes", "$OctetsTransmittedTotal"},
+		{"TxErrs", "$FramesTransmittedwithError"},
+	}
+)
+
+// portStat is a sample of the well-known counters of a port.
+type portStat struct {
+	dev      uint32
+	counters map[string]uint64
+}
+
+var portStatsCmd = &cobra.Command{
+	Use:   "stats [PORT...]",
+	Short: "Show port statistics",
+	Long: `Show the counters of ports from the $PORT_STAT table. With --watch, the
+counters are read on the interval and the per second rates since the previous
+read are shown.
+
+  bfcli port stats 1/0 2/0
+  bfcli port stats --watch 1s
+  bfcli port stats 1/0 --clear`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findNonP4Table(PORT_STAT_TABLE)
+		if table == nil {
+			exit(1)
+		}
+		var keys []*p4.TableKey
+		for _, name := range args {
+			dev, err := portDevice(name)
+			if err != nil {
+				fmt.Println(err)
+				exit(1)
+			}
+			key, err := buildTableKey(table, []string{fmt.Sprintf("%s=%d", DEV_PORT, dev)})
+			if err != nil {
+				fmt.Println(err)
+				exit(1)
+			}
+			keys = append(keys, key)
+		}
+
+		if portStatsClear {
+			if err := clearPortStats(cli, ctx, table, keys); err != nil {
+				fatalf("Got error when clearing port statistics: %v", err)
+			}
+			fmt.Println("Port statistics are cleared")
+			return
+		}
+
+		prev := readPortStats(cli, ctx, table, keys)
+		prevTime := time.Now()
+		printPortStats(prev, nil, 0)
+		if portStatsWatch <= 0 {
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(portStatsWatch):
+			}
+			cur := readPortStats(cli, ctx, table, keys)
+			now := time.Now()
+			fmt.Printf("\n%s\n", now.Format("2006-01-02 15:04:05"))
+			printPortStats(cur, prev, now.Sub(prevTime))
+			prev, prevTime = cur, now
+		}
+	},
+}
+
+func readPortStats(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table, keys []*p4.TableKey) []portStat {
+	var entities []*p4.Entity
+	for _, key := range keys {
+		entities = append(entities, portStatEntity(table, key))
+	}
+	if len(entities) == 0 {
+		entities = append(entities, portStatEntity(table, nil))
+	}
+
+	devField, _ := searchField(keyFields(table), DEV_PORT)
+	data := dataFields(table)
+	var stats []portStat
+	for _, e := range readEntities(cli, ctx, entities...) {
+		tbl := e.GetTableEntry()
+		if tbl == nil {
+			continue
+		}
+		stat := portStat{counters: map[string]uint64{}}
+		for _, f := range tbl.GetKey().GetFields() {
+			if f.FieldId == devField.ID {
+				stat.dev = uint32(decodeValue(f.GetExact().GetValue()).Uint64())
+			}
+		}
+		for _, c := range portStatCounters {
+			if field, ok := searchField(data, c.name); ok {
+				if v := collectDataValues(tbl.GetData().GetFields(), field.ID); len(v) != 0 {
+					stat.counters[c.name] = v[0].Uint64()
+				}
+			}
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].dev < stats[j].dev
+	})
+	return stats
+}
+
+func portStatEntity(table *util.Table, key *p4.TableKey) *p4.Entity {
+	entity := tableEntryEntity(table, key, nil)
+	entity.GetTableEntry().TableReadFlag = &p4.TableReadFlag{FromHw: true}
+	return entity
+}
+
+// printPortStats prints the counters, and the rates against prev when prev
+// is given.
+func printPortStats(stats, prev []portStat, elapsed time.Duration) {
+	fmt.Printf("%-8s %-8s", "Port", "DevPort")
+	for _, c := range portStatCounters {
+		fmt.Printf(" %16s", c.label)
+	}
+	if prev != nil {
+		fmt.Printf(" %12s %12s %12s %12s", "RxPps", "RxBps", "TxPps", "TxBps")
+	}
+	fmt.Println()
+
+	for _, s := range stats {
+		name, ok := portName(s.dev)
+		if !ok {
+			name = "-"
+		}
+		fmt.Printf("%-8s %-8d", name, s.dev)
+		for _, c := range portStatCounters {
+			fmt.Printf(" %16d", s.counters[c.name])
+		}
+		if prev != nil {
+			var last *portStat
+			for i := range prev {
+				if prev[i].dev == s.dev {
+					last = &prev[i]
+				}
+			}
+			rate := func(name string, scale float64) string {
+				if last == nil || elapsed <= 0 || s.counters[name] < last.counters[name] {
+					return "-"
+				}
+				return humanRate(float64(s.counters[name]-last.counters[name]) * scale / elapsed.Seconds())
+			}
+			fmt.Printf(" %12s %12s %12s %12s",
+				rate("$FramesReceivedAll", 1), rate("$OctetsReceived", 8),
+				rate("$FramesTransmittedAll", 1), rate("$OctetsTransmittedTotal", 8))
+		}
+		fmt.Println()
+	}
+}
+
+func humanRate(v float64) string {
+	for _, unit := range []string{"", "K", "M", "G"} {
+		if v < 1000 {
+			return fmt.Sprintf("%.1f%s", v, unit)
+		}
+		v /= 1000
+	}
+	return fmt.Sprintf("%.1fT", v)
+}
+
+// clearPortStats resets every counter of the ports, or of all ports when no
+// key is given, to zero.
+func clearPortStats(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table, keys []*p4.TableKey) error {
+	if len(keys) == 0 {
+		devField, _ := searchField(keyFields(table), DEV_PORT)
+		for _, e := range readEntities(cli, ctx, tableEntryEntity(table, nil, nil)) {
+			for _, f := range e.GetTableEntry().GetKey().GetFields() {
+				if f.FieldId == devField.ID {
+					keys = append(keys, &p4.TableKey{Fields: []*p4.KeyField{f}})
+				}
+			}
+		}
+	}
+
+	var assignments []string
+	for _, d := range dataFields(table) {
+		assignments = append(assignments, d.Name+"=0")
+	}
+	data, err := buildTableData(table, assignments)
+	if err != nil {
+		return err
+	}
+	var updates []*p4.Update
+	for _, key := range keys {
+		updates = append(updates, &p4.Update{
+			Type:   p4.Update_MODIFY,
+			Entity: tableEntryEntity(table, key, data),
+		})
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return writeUpdates(cli, ctx, updates...)
+}
+
+func init() {
+	portCmd.AddCommand(portStatsCmd)
+	portStatsCmd.Flags().DurationVarP(&portStatsWatch, "watch", "w", 0, "Read the counters on the interval and show rates, e.g. 1s")
+	portStatsCmd.Flags().BoolVar(&portStatsClear, "clear", false, "Reset the counters to zero")
+}
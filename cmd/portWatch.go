@@ -0,0 +1,145 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+var (
+	portWatchExec string
+)
+
+var portWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Args:  cobra.ExactArgs(0),
+	Short: "Watch port status changes",
+	Long: `Enable port status notification on the $PORT table and print every link up
+and down with timestamp and port name. With --exec, the command is run through
+the shell on every change with the environment variables BFCLI_PORT,
+BFCLI_DEV_PORT and BFCLI_PORT_STATE (up or down).
+
+  bfcli port watch --exec 'logger -t bfcli "$BFCLI_PORT is $BFCLI_PORT_STATE"'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx, stop := interruptContext(*ctxAddr)
+		defer stop()
+
+		table := findNonP4Table(PORT_TABLE)
+		if table == nil {
+			exit(1)
+		}
+
+		stream, err := cli.StreamChannel(ctx)
+		if err != nil {
+			fatalf("Got error when opening stream channel: %v", err)
+		}
+		err = stream.Send(&p4.StreamMessageRequest{
+			Update: &p4.StreamMessageRequest_Subscribe{
+				Subscribe: &p4.Subscribe{
+					DeviceId: DEVICE_ID,
+					Notifications: &p4.Subscribe_Notifications{
+						EnablePortStatusChangeNotifications: true,
+					},
+				},
+			},
+		})
+		if err != nil {
+			fatalf("Got error when subscribing: %v", err)
+		}
+
+		err = writeUpdates(cli, ctx, &p4.Update{
+			Type: p4.Update_INSERT,
+			Entity: &p4.Entity{
+				Entity: &p4.Entity_TableAttribute{
+					TableAttribute: &p4.TableAttribute{
+						TableId: table.ID,
+						Attribute: &p4.TableAttribute_PortStatusNotify{
+							PortStatusNotify: &p4.PortStatusChg{Enable: true},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			fatalf("Got error when enabling port status notification: %v", err)
+		}
+		fmt.Println("Watching port status changes, press Ctrl+C to stop")
+
+		devField, _ := searchField(keyFields(table), DEV_PORT)
+		for {
+			rsp, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil && isCanceled(ctx, err) {
+				fmt.Println("Stopped watching port status changes")
+				return
+			}
+			if err != nil {
+				fatalf("Got error: %v", err)
+			}
+			notification := rsp.GetPortStatusChangeNotification()
+			if notification == nil {
+				continue
+			}
+
+			var dev uint32
+			for _, f := range notification.GetPortKey().GetKey().GetFields() {
+				if f.FieldId == devField.ID {
+					dev = uint32(decodeValue(f.GetExact().GetValue()).Uint64())
+				}
+			}
+			name := strconv.FormatUint(uint64(dev), 10)
+			if n, ok := portName(dev); ok {
+				name = n
+			}
+			state := "down"
+			if notification.GetPortUp() {
+				state = "up"
+			}
+			fmt.Printf("%s port %s (dev %d) is %s\n", time.Now().Format("2006-01-02 15:04:05.000"), name, dev, state)
+
+			if portWatchExec != "" {
+				hook := exec.Command("sh", "-c", portWatchExec)
+				hook.Stdout = os.Stdout
+				hook.Stderr = os.Stderr
+				hook.Env = append(os.Environ(),
+					"BFCLI_PORT="+name,
+					"BFCLI_DEV_PORT="+strconv.FormatUint(uint64(dev), 10),
+					"BFCLI_PORT_STATE="+state)
+				if err := hook.Run(); err != nil {
+					fmt.Printf("The exec hook failed: %v\n", err)
+				}
+			}
+		}
+	},
+}
+
+func init() {
+	portCmd.AddCommand(portWatchCmd)
+	portWatchCmd.Flags().StringVar(&portWatchExec, "exec", "", "The shell command to run on every port status change")
+}
@@ -0,0 +1,235 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+const PROFILE_TABLE_TYPE = "Action"
+
+var (
+	profileMemberId     uint32
+	profileMemberAction string
+	profileMemberParams []string
+)
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage action profiles",
+	Long:  `Manage the members of action profile tables`,
+}
+
+var profileMemberCmd = &cobra.Command{
+	Use:   "member",
+	Short: "Manage action profile members",
+	Long:  `Add, delete or list the members of an action profile`,
+}
+
+var profileMemberAddCmd = &cobra.Command{
+	Use:   "add PROFILE-NAME",
+	Args:  cobra.ExactArgs(1),
+	Short: "Add a member into action profile",
+	Long: `Add a member with an action and its parameters into action profile.
+
+  bfcli profile member add SwitchIngress.nexthop_profile --id 1 --action set_nexthop -p port=1`,
+	ValidArgsFunction: completeTableType(PROFILE_TABLE_TYPE),
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTypedTable(args[0], PROFILE_TABLE_TYPE)
+		if table == nil {
+			exit(1)
+		}
+		key, err := buildTableKey(table, []string{fmt.Sprintf("%s=%d", ACTION_MEMBER_ID, profileMemberId)})
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		data, err := buildActionData(table, profileMemberAction, profileMemberParams)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		err = writeUpdates(cli, ctx, &p4.Update{
+			Type:   p4.Update_INSERT,
+			Entity: tableEntryEntity(table, key, data),
+		})
+		if err != nil {
+			fatalf("Got error when adding member: %v", err)
+		}
+		fmt.Printf("Member %d is added into %s\n", profileMemberId, table.Name)
+	},
+}
+
+var profileMemberDelCmd = &cobra.Command{
+	Use:   "del PROFILE-NAME",
+	Args:  cobra.ExactArgs(1),
+	Short: "Delete a member from action profile",
+	Long: `Delete a member from action profile. The member must not be referenced by
+any flow or selector group.
+
+  bfcli profile member del SwitchIngress.nexthop_profile --id 1`,
+	ValidArgsFunction: completeTableType(PROFILE_TABLE_TYPE),
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTypedTable(args[0], PROFILE_TABLE_TYPE)
+		if table == nil {
+			exit(1)
+		}
+		key, err := buildTableKey(table, []string{fmt.Sprintf("%s=%d", ACTION_MEMBER_ID, profileMemberId)})
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		err = writeUpdates(cli, ctx, &p4.Update{
+			Type:   p4.Update_DELETE,
+			Entity: tableEntryEntity(table, key, nil),
+		})
+		if err != nil {
+			fatalf("Got error when deleting member: %v", err)
+		}
+		fmt.Printf("Member %d is deleted from %s\n", profileMemberId, table.Name)
+	},
+}
+
+var profileMemberListCmd = &cobra.Command{
+	Use:               "list PROFILE-NAME",
+	Args:              cobra.ExactArgs(1),
+	Short:             "List the members of action profile",
+	Long:              `List the members of action profile with their actions and parameters`,
+	ValidArgsFunction: completeTableType(PROFILE_TABLE_TYPE),
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTypedTable(args[0], PROFILE_TABLE_TYPE)
+		if table == nil {
+			exit(1)
+		}
+
+		members := readProfileMembers(cli, ctx, table)
+		if len(members) == 0 {
+			fmt.Printf("The members in %s is null\n", table.Name)
+			return
+		}
+		for _, m := range members {
+			fmt.Printf("%-12s: %d\n", "Member ID", m.id)
+			fmt.Printf("%-12s: %s\n", "Action", formatEntryData(table, m.data))
+		}
+	},
+}
+
+// profileMember is a member of an action profile and its action data.
+type profileMember struct {
+	id   uint32
+	data *p4.TableData
+}
+
+func readProfileMembers(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table) []profileMember {
+	field, _ := searchField(keyFields(table), ACTION_MEMBER_ID)
+
+	var members []profileMember
+	for _, e := range readEntities(cli, ctx, tableEntryEntity(table, nil, nil)) {
+		tbl := e.GetTableEntry()
+		if tbl == nil {
+			continue
+		}
+		for _, f := range tbl.GetKey().GetFields() {
+			if f.FieldId == field.ID {
+				members = append(members, profileMember{
+					id:   uint32(decodeValue(f.GetExact().GetValue()).Uint64()),
+					data: tbl.GetData(),
+				})
+			}
+		}
+	}
+	return members
+}
+
+// findTypedTable finds a table and checks it is of the expected table type,
+// it prints the reason and returns nil on failure.
+func findTypedTable(name, tableType string) *util.Table {
+	table := findTable(name)
+	if table == nil {
+		fmt.Printf("Can not found table with name: %s\n", name)
+		return nil
+	}
+	if table.TableType != tableType {
+		fmt.Printf("The table %s is not %s table but %s\n", table.Name, tableType, table.TableType)
+		return nil
+	}
+	return table
+}
+
+// completeTableType completes the names of P4 tables of given type.
+func completeTableType(tableType string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		_, _, conn, cancel, p4Info, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+
+		var argsList []string
+		for _, t := range p4Info.Tables {
+			if t.TableType == tableType && strings.HasPrefix(t.Name, toComplete) {
+				argsList = append(argsList, t.Name)
+			}
+		}
+		return argsList, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileMemberCmd)
+	profileMemberCmd.AddCommand(profileMemberAddCmd)
+	profileMemberCmd.AddCommand(profileMemberDelCmd)
+	profileMemberCmd.AddCommand(profileMemberListCmd)
+
+	profileMemberAddCmd.Flags().Uint32Var(&profileMemberId, "id", 0, "The member ID")
+	profileMemberAddCmd.Flags().StringVarP(&profileMemberAction, "action", "a", "", "The action of the member")
+	profileMemberAddCmd.Flags().StringArrayVarP(&profileMemberParams, "param", "p", nil, "The action parameter NAME=VALUE, can be repeated")
+	profileMemberAddCmd.MarkFlagRequired("id")
+	profileMemberAddCmd.MarkFlagRequired("action")
+	registerFlagCompletion(profileMemberAddCmd, "action", completeActionFlag)
+	registerFlagCompletion(profileMemberAddCmd, "param", completeParamFlag)
+
+	profileMemberDelCmd.Flags().Uint32Var(&profileMemberId, "id", 0, "The member ID")
+	profileMemberDelCmd.MarkFlagRequired("id")
+}
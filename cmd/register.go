@@ -0,0 +1,345 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"strconv"
+	"strings"
+)
+
+const (
+	REGISTER_TABLE_TYPE = "Register"
+	REGISTER_INDEX      = "$REGISTER_INDEX"
+	// MAX_UNSIZED_RANGE bounds the index ranges of tables of unknown size.
+	MAX_UNSIZED_RANGE = 65536
+)
+
+var (
+	registerIndex  uint32
+	registerRange  string
+	registerFromHw bool
+)
+
+// registerCmd represents the register command
+var registerCmd = &cobra.Command{
+	Use:   "register",
+	Short: "Read or write register tables",
+	Long:  `Read or write the register tables of the P4 program by index`,
+}
+
+var registerReadCmd = &cobra.Command{
+	Use:   "read REGISTER-NAME",
+	Args:  cobra.ExactArgs(1),
+	Short: "Read the values of a register",
+	Long: `Read the values of a register. The registers are synced from hardware before
+reading, and every value is displayed per pipe.
+
+  bfcli register read SwitchIngress.reg --index 5
+  bfcli register read SwitchIngress.reg --range 0-15`,
+	ValidArgsFunction: completeRegisterName,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findRegisterTable(args[0])
+		if table == nil {
+			exit(1)
+		}
+
+		indexes, err := selectIndexes(cmd, table, registerIndex, registerRange)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		results, err := readIndexedEntries(cli, ctx, table, REGISTER_INDEX, "SyncRegisters", indexes, registerFromHw)
+		if err != nil {
+			fatalf("Got error when reading registers: %v", err)
+		}
+		if len(results) == 0 {
+			fmt.Printf("The register %s has no value\n", table.Name)
+			return
+		}
+		data := dataFields(table)
+		for _, tbl := range results {
+			if index, ok := entryIndex(table, REGISTER_INDEX, tbl); ok {
+				fmt.Printf("%-12s: %s\n", "Index", index)
+			}
+			for _, d := range data {
+				values := collectDataValues(tbl.GetData().GetFields(), d.ID)
+				if len(values) == 0 {
+					continue
+				}
+				labelled := make([]string, 0, len(values))
+				for pipe, v := range values {
+					labelled = append(labelled, fmt.Sprintf("pipe%d: %s", pipe, v))
+				}
+				fmt.Printf("%-12s: [%s]\n", d.Name, strings.Join(labelled, ", "))
+			}
+			fmt.Printf("------------------\n")
+		}
+	},
+}
+
+var registerWriteCmd = &cobra.Command{
+	Use:   "write REGISTER-NAME FIELD=VALUE...",
+	Args:  cobra.MinimumNArgs(2),
+	Short: "Write the values of a register",
+	Long: `Write the value of a register at the given index, the value is written to
+every pipe. The field name "value" refers to the only data field of the register.
+
+  bfcli register write SwitchIngress.reg --index 5 value=10`,
+	ValidArgsFunction: completeRegisterName,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findRegisterTable(args[0])
+		if table == nil {
+			exit(1)
+		}
+
+		key, err := registerKey(table, registerIndex)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		data := dataFields(table)
+		tableData := &p4.TableData{}
+		for _, arg := range args[1:] {
+			name, value, err := parseAssignment(arg)
+			if err != nil {
+				fmt.Println(err)
+				exit(1)
+			}
+			field, ok := searchField(data, name)
+			if !ok && name == "value" && len(data) == 1 {
+				field, ok = data[0], FOUND
+			}
+			if !ok {
+				fmt.Printf("Can not found data field %s in register %s\n", name, table.Name)
+				exit(1)
+			}
+			stream, err := encodeValue(value, field.bitWidth())
+			if err != nil {
+				fmt.Println(err)
+				exit(1)
+			}
+			tableData.Fields = append(tableData.Fields, &p4.DataField{
+				FieldId: field.ID,
+				Value:   &p4.DataField_Stream{Stream: stream},
+			})
+		}
+
+		entity := registerEntity(table, key)
+		entity.GetTableEntry().Data = tableData
+		err = writeUpdates(cli, ctx, &p4.Update{Type: p4.Update_MODIFY, Entity: entity})
+		if err != nil {
+			fatalf("Got error when writing register: %v", err)
+		}
+		fmt.Printf("Register %s[%d] is written\n", table.Name, registerIndex)
+	},
+}
+
+func completeRegisterName(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	_, _, conn, cancel, p4Info, _ := initConfigClient()
+	defer conn.Close()
+	defer cancel()
+
+	var argsList []string
+	for _, t := range p4Info.Tables {
+		if t.TableType == REGISTER_TABLE_TYPE && strings.HasPrefix(t.Name, toComplete) {
+			argsList = append(argsList, t.Name)
+		}
+	}
+	return argsList, cobra.ShellCompDirectiveNoFileComp
+}
+
+func findRegisterTable(name string) *util.Table {
+	table := findTable(name)
+	if table == nil {
+		fmt.Printf("Can not found table with name: %s\n", name)
+		return nil
+	}
+	if table.TableType != REGISTER_TABLE_TYPE {
+		fmt.Printf("The table %s is not a register but %s\n", table.Name, table.TableType)
+		return nil
+	}
+	return table
+}
+
+func registerKey(table *util.Table, index uint32) (*p4.TableKey, error) {
+	return indexKey(table, REGISTER_INDEX, index)
+}
+
+// indexKey builds the key of an indexed table such as a register or counter,
+// whose only key field is the index.
+func indexKey(table *util.Table, name string, index uint32) (*p4.TableKey, error) {
+	field, ok := searchField(keyFields(table), name)
+	if !ok {
+		return nil, fmt.Errorf("can not found %s in table %s", name, table.Name)
+	}
+	if table.Size != 0 && int64(index) >= int64(table.Size) {
+		return nil, fmt.Errorf("index %d is out of table size %d", index, table.Size)
+	}
+	value, err := encodeValue(strconv.FormatUint(uint64(index), 10), field.bitWidth())
+	if err != nil {
+		return nil, err
+	}
+	return &p4.TableKey{
+		Fields: []*p4.KeyField{
+			{
+				FieldId:   field.ID,
+				MatchType: &p4.KeyField_Exact_{Exact: &p4.KeyField_Exact{Value: value}},
+			},
+		},
+	}, nil
+}
+
+func registerEntity(table *util.Table, key *p4.TableKey) *p4.Entity {
+	return indexEntity(table, key, registerFromHw)
+}
+
+func indexEntity(table *util.Table, key *p4.TableKey, fromHw bool) *p4.Entity {
+	return &p4.Entity{
+		Entity: &p4.Entity_TableEntry{
+			TableEntry: &p4.TableEntry{
+				TableId:       table.ID,
+				Key:           key,
+				TableReadFlag: &p4.TableReadFlag{FromHw: fromHw},
+			},
+		},
+	}
+}
+
+// selectIndexes returns the indexes given by the --index or --range flag of an
+// indexed table, none means the whole table.
+func selectIndexes(cmd *cobra.Command, table *util.Table, index uint32, indexRange string) ([]uint32, error) {
+	if cmd.Flags().Changed("index") && indexRange != "" {
+		return nil, fmt.Errorf("the --index and --range can not be used together")
+	}
+	if cmd.Flags().Changed("index") {
+		return []uint32{index}, nil
+	}
+	if indexRange != "" {
+		return tableIndexRange(table, indexRange)
+	}
+	return nil, nil
+}
+
+// readIndexedEntries syncs an indexed table from hardware by the operation,
+// such as SyncRegisters, then reads the entries at the indexes or all of them.
+func readIndexedEntries(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table, indexName, operation string, indexes []uint32, fromHw bool) ([]*p4.TableEntry, error) {
+	var entities []*p4.Entity
+	if len(indexes) == 0 {
+		entities = append(entities, indexEntity(table, nil, fromHw))
+	}
+	for _, i := range indexes {
+		key, err := indexKey(table, indexName, i)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, indexEntity(table, key, fromHw))
+	}
+	if err := syncTable(cli, ctx, table.ID, operation); err != nil {
+		return nil, fmt.Errorf("can not sync %s: %v", table.Name, err)
+	}
+
+	var entries []*p4.TableEntry
+	for _, e := range readEntities(cli, ctx, entities...) {
+		if tbl := e.GetTableEntry(); tbl != nil {
+			entries = append(entries, tbl)
+		}
+	}
+	return entries, nil
+}
+
+// entryIndex returns the value of the index key field of an entry.
+func entryIndex(table *util.Table, indexName string, tbl *p4.TableEntry) (string, bool) {
+	keys := keyFields(table)
+	for _, f := range tbl.GetKey().GetFields() {
+		if k, ok := searchFieldById(keys, f.FieldId); ok && k.Name == indexName {
+			return decodeValue(f.GetExact().GetValue()).String(), true
+		}
+	}
+	return "", false
+}
+
+// parseIndexRange parses an inclusive range such as "0-15".
+func parseIndexRange(s string) (uint32, uint32, error) {
+	bounds := strings.SplitN(s, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %q, expect A-B", s)
+	}
+	low, err := strconv.ParseUint(bounds[0], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range %q: %v", s, err)
+	}
+	high, err := strconv.ParseUint(bounds[1], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range %q: %v", s, err)
+	}
+	if low > high {
+		return 0, 0, fmt.Errorf("invalid range %q, low bound is larger than high bound", s)
+	}
+	return uint32(low), uint32(high), nil
+}
+
+// tableIndexRange lists the indexes of a range such as "0-15" after checking
+// it against the size of the indexed table. When the size is unknown, the
+// range may hold at most MAX_UNSIZED_RANGE indexes.
+func tableIndexRange(table *util.Table, s string) ([]uint32, error) {
+	low, high, err := parseIndexRange(s)
+	if err != nil {
+		return nil, err
+	}
+	if table.Size != 0 && int64(high) >= int64(table.Size) {
+		return nil, fmt.Errorf("index %d is out of table size %d", high, table.Size)
+	}
+	if table.Size == 0 && uint64(high)-uint64(low) >= MAX_UNSIZED_RANGE {
+		return nil, fmt.Errorf("the size of table %s is unknown, a range may hold at most %d indexes", table.Name, MAX_UNSIZED_RANGE)
+	}
+	var indexes []uint32
+	// A uint32 counter would wrap around when high is the largest index.
+	for i := uint64(low); i <= uint64(high); i++ {
+		indexes = append(indexes, uint32(i))
+	}
+	return indexes, nil
+}
+
+func init() {
+	rootCmd.AddCommand(registerCmd)
+	registerCmd.AddCommand(registerReadCmd)
+	registerCmd.AddCommand(registerWriteCmd)
+
+	registerReadCmd.Flags().Uint32VarP(&registerIndex, "index", "i", 0, "The index of register to read")
+	registerReadCmd.Flags().StringVarP(&registerRange, "range", "r", "", "The index range of register to read, e.g. 0-15")
+	registerReadCmd.Flags().BoolVar(&registerFromHw, "from-hw", false, "Read the values from hardware instead of software shadow")
+
+	registerWriteCmd.Flags().Uint32VarP(&registerIndex, "index", "i", 0, "The index of register to write")
+	registerWriteCmd.MarkFlagRequired("index")
+}
@@ -0,0 +1,83 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"testing"
+)
+
+func TestParseIndexRange(t *testing.T) {
+	tests := []struct {
+		s         string
+		low, high uint32
+		wantErr   bool
+	}{
+		{"0-15", 0, 15, false},
+		{"7-7", 7, 7, false},
+		{"0-4294967295", 0, 4294967295, false},
+		{"5-3", 0, 0, true},
+		{"5", 0, 0, true},
+		{"a-3", 0, 0, true},
+		{"0-4294967296", 0, 0, true},
+	}
+	for _, tt := range tests {
+		low, high, err := parseIndexRange(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseIndexRange(%q) error = %v, want error %t", tt.s, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (low != tt.low || high != tt.high) {
+			t.Errorf("parseIndexRange(%q) = %d, %d, want %d, %d", tt.s, low, high, tt.low, tt.high)
+		}
+	}
+}
+
+func TestTableIndexRange(t *testing.T) {
+	sized := testTable(t, `{"name": "SwitchIngress.reg", "table_type": "Register", "size": 16}`)
+	unsized := testTable(t, `{"name": "SwitchIngress.reg", "table_type": "Register"}`)
+	tests := []struct {
+		name    string
+		s       string
+		want    int
+		wantErr bool
+	}{
+		{"within size", "0-15", 16, false},
+		{"beyond size", "0-16", 0, true},
+		{"largest index", "4294967295-4294967295", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := tableIndexRange(sized, tt.s)
+		if (err != nil) != tt.wantErr || len(got) != tt.want {
+			t.Errorf("%s: tableIndexRange(%q) = %d indexes, %v", tt.name, tt.s, len(got), err)
+		}
+	}
+
+	// The loop must end when the range reaches the largest uint32.
+	got, err := tableIndexRange(unsized, "4294967294-4294967295")
+	if err != nil || len(got) != 2 || got[1] != 4294967295 {
+		t.Errorf("tableIndexRange at the largest index = %v, %v", got, err)
+	}
+
+	// Without a size, open ranges are rejected instead of listing every index.
+	for _, s := range []string{"0-4294967295", "0-65536"} {
+		if got, err := tableIndexRange(unsized, s); err == nil {
+			t.Errorf("tableIndexRange(%q) without size = %d indexes, want error", s, len(got))
+		}
+	}
+	if got, err := tableIndexRange(unsized, "0-65535"); err != nil || len(got) != MAX_UNSIZED_RANGE {
+		t.Errorf("tableIndexRange(\"0-65535\") without size = %d indexes, %v", len(got), err)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	runStopOnError bool
+	runVars        []string
+)
+
+// variablePattern matches a variable definition line such as "PORT=1".
+var variablePattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// referencePattern matches $$, ${NAME} and $NAME in a script line.
+var referencePattern = regexp.MustCompile(`\$(\$|\{[A-Za-z_][A-Za-z0-9_]*\}|[A-Za-z_][A-Za-z0-9_]*)`)
+
+// scriptLine is a line of a script with its location for error messages.
+type scriptLine struct {
+	file string
+	no   int
+	text string
+}
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run SCRIPT-FILE...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Run bfcli commands from script files",
+	Long: `Run the bfcli commands of script files over a single connection, one command
+per line with an optional bfcli prefix. Lines starting with # are comments.
+
+A line NAME=VALUE defines a variable, and $NAME or ${NAME} in the following
+lines is replaced by its value. $NAME is kept as it is when no such variable
+is defined, so data fields such as $MATCH_PRIORITY need no escaping, while an
+undefined ${NAME} is an error. Use $$ for a literal $. Variables given with
+--var take precedence over the definitions in the scripts.
+
+  # setup.bfc
+  NH_PORT=1
+  set-flow SwitchIngress.ipv4_lpm ipv4_dst=10.0.0.0/8 --action set_nexthop -p port=$NH_PORT
+  set-flow SwitchIngress.ipv4_lpm ipv4_dst=10.1.0.0/16 --action set_nexthop -p port=$NH_PORT
+  register read SwitchIngress.reg --index 1
+
+  bfcli run setup.bfc --var NH_PORT=2 --stop-on-error`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// The flags are reset by every command of the script, keep them first.
+		stopOnError := runStopOnError
+		vars := map[string]string{}
+		fixed := map[string]bool{}
+		for _, v := range runVars {
+			m := variablePattern.FindStringSubmatch(v)
+			if m == nil {
+				fmt.Printf("Invalid variable %q, expect NAME=VALUE\n", v)
+				exit(1)
+			}
+			vars[m[1]], fixed[m[1]] = m[2], true
+		}
+
+		var lines []scriptLine
+		for _, file := range args {
+			l, err := readScript(file)
+			if err != nil {
+				fmt.Println(err)
+				exit(1)
+			}
+			lines = append(lines, l...)
+		}
+
+		// Inside the shell the session of the shell is used.
+		owned := shellSession == nil
+		if owned {
+			startSession()
+		}
+
+		start := time.Now()
+		succeeded, failed := runScript(lines, vars, fixed, stopOnError)
+		if owned {
+			closeSession()
+		}
+
+		fmt.Printf("Ran %d commands in %v: %d succeeded, %d failed\n",
+			succeeded+failed, time.Since(start).Round(time.Millisecond), succeeded, failed)
+		if failed != 0 {
+			exit(1)
+		}
+	},
+}
+
+// runScript runs the lines of a script within the session and counts the
+// commands which succeeded and failed.
+func runScript(lines []scriptLine, vars map[string]string, fixed map[string]bool, stopOnError bool) (succeeded, failed int) {
+	for i, line := range lines {
+		words, err := expandScriptLine(line.text, vars, fixed)
+		if err == nil && len(words) != 0 {
+			switch words[0] {
+			case "run", "shell":
+				err = fmt.Errorf("%s can not be used in a script", words[0])
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s:%d: %v\n", line.file, line.no, err)
+			failed++
+		} else if len(words) == 0 {
+			continue
+		} else if runShellCommand(words) != 0 {
+			fmt.Fprintf(os.Stderr, "%s:%d: command failed\n", line.file, line.no)
+			failed++
+		} else {
+			succeeded++
+		}
+		if failed != 0 && stopOnError {
+			fmt.Printf("Stopped at %s:%d, %d lines are skipped\n", line.file, line.no, len(lines)-i-1)
+			break
+		}
+	}
+	return succeeded, failed
+}
+
+// readScript reads the lines of a script, leaving out blank lines and
+// comments.
+func readScript(file string) ([]scriptLine, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []scriptLine
+	scanner := bufio.NewScanner(f)
+	for no := 1; scanner.Scan(); no++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		lines = append(lines, scriptLine{file: file, no: no, text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can not read %s: %v", file, err)
+	}
+	return lines, nil
+}
+
+// expandScriptLine replaces the variables of a line and splits it into the
+// words of a command. A variable definition updates vars and returns no
+// words, unless the variable is fixed by --var.
+func expandScriptLine(text string, vars map[string]string, fixed map[string]bool) ([]string, error) {
+	var missing []string
+	expanded := referencePattern.ReplaceAllStringFunc(text, func(ref string) string {
+		name := ref[1:]
+		if name == "$" {
+			return "$"
+		}
+		braced := strings.HasPrefix(name, "{")
+		name = strings.Trim(name, "{}")
+		value, ok := vars[name]
+		if !ok {
+			if braced {
+				missing = append(missing, name)
+			}
+			return ref
+		}
+		return value
+	})
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("undefined variable %s", strings.Join(missing, ", "))
+	}
+
+	words, err := splitWords(expanded)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 1 {
+		if m := variablePattern.FindStringSubmatch(words[0]); m != nil {
+			if !fixed[m[1]] {
+				vars[m[1]] = m[2]
+			}
+			return nil, nil
+		}
+	}
+	if len(words) != 0 && words[0] == "bfcli" {
+		words = words[1:]
+	}
+	return words, nil
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().BoolVar(&runStopOnError, "stop-on-error", false, "Stop at the first failed command")
+	runCmd.Flags().StringArrayVar(&runVars, "var", nil, "Define a variable NAME=VALUE, can be repeated")
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestExpandScriptLine(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    []string
+		vars    map[string]string
+		wantErr bool
+	}{
+		{"dump $TABLE", []string{"dump", "t1"}, map[string]string{"TABLE": "t1", "PORT": "1"}, false},
+		{"bfcli set-flow t k=${PORT}0", []string{"set-flow", "t", "k=10"}, map[string]string{"TABLE": "t1", "PORT": "1"}, false},
+		{"echo $$HOME", []string{"echo", "$HOME"}, map[string]string{"TABLE": "t1", "PORT": "1"}, false},
+		{"PORT=2", nil, map[string]string{"TABLE": "t1", "PORT": "2"}, false},
+		{"TABLE=t2", nil, map[string]string{"TABLE": "t1", "PORT": "1"}, false},
+		{"dump $MISSING", []string{"dump", "$MISSING"}, map[string]string{"TABLE": "t1", "PORT": "1"}, false},
+		{"set-flow t k=$PORT $MATCH_PRIORITY=10", []string{"set-flow", "t", "k=1", "$MATCH_PRIORITY=10"}, map[string]string{"TABLE": "t1", "PORT": "1"}, false},
+		{"set-flow t '$MATCH_PRIORITY=10'", []string{"set-flow", "t", "$MATCH_PRIORITY=10"}, map[string]string{"TABLE": "t1", "PORT": "1"}, false},
+		{"dump ${MISSING}", nil, map[string]string{"TABLE": "t1", "PORT": "1"}, true},
+		{`dump "t1`, nil, map[string]string{"TABLE": "t1", "PORT": "1"}, true},
+	}
+	for _, tt := range tests {
+		vars := map[string]string{"TABLE": "t1", "PORT": "1"}
+		fixed := map[string]bool{"TABLE": true}
+		got, err := expandScriptLine(tt.text, vars, fixed)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandScriptLine(%q) error = %v, want error %t", tt.text, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandScriptLine(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		if !reflect.DeepEqual(vars, tt.vars) {
+			t.Errorf("expandScriptLine(%q) leaves variables %v, want %v", tt.text, vars, tt.vars)
+		}
+	}
+}
+
+// withTestSession runs the commands of the test against a session without a
+// device, so they fail on anything but local validation.
+func withTestSession(t *testing.T) {
+	t.Helper()
+	shellSession = &session{ctx: context.Background(), conn: nopCloser{}, cancel: func() {}}
+	t.Cleanup(func() {
+		shellSession = nil
+	})
+}
+
+func TestRunScriptCountsFailures(t *testing.T) {
+	withTestSession(t)
+	lines := []scriptLine{
+		{file: "test.bfc", no: 1, text: "TABLE=SwitchIngress.no_such_table"},
+		{file: "test.bfc", no: 2, text: "set-flow $TABLE dst_addr=10.0.0.1 --action drop"},
+		{file: "test.bfc", no: 3, text: "dump $TABLE"},
+		{file: "test.bfc", no: 4, text: "search no_such_name"},
+		{file: "test.bfc", no: 5, text: "run other.bfc"},
+	}
+	tests := []struct {
+		name        string
+		stopOnError bool
+		succeeded   int
+		failed      int
+	}{
+		{"keep going", false, 1, 3},
+		{"stop on error", true, 0, 1},
+	}
+	for _, tt := range tests {
+		succeeded, failed := runScript(lines, map[string]string{}, map[string]bool{}, tt.stopOnError)
+		if succeeded != tt.succeeded || failed != tt.failed {
+			t.Errorf("%s: runScript = %d succeeded, %d failed, want %d, %d", tt.name, succeeded, failed, tt.succeeded, tt.failed)
+		}
+	}
+}
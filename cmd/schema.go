@@ -0,0 +1,329 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// The schema argument naming the switch of --server.
+	LIVE_SCHEMA = "live"
+	// How long to wait for a switch given by address.
+	SCHEMA_DIAL_TIMEOUT = 10 * time.Second
+)
+
+var schemaIgnoreIds bool
+
+// schemaDiff collects the lines of a schema diff and whether any of them
+// breaks existing flows.
+type schemaDiff struct {
+	lines    []string
+	breaking int
+}
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Compare the schemas of P4 programs",
+	Long:  `Compare the BfRt schemas of P4 programs`,
+}
+
+var schemaDiffCmd = &cobra.Command{
+	Use:   "diff OLD NEW",
+	Args:  cobra.ExactArgs(2),
+	Short: "Show the differences between two schemas",
+	Long: `Show the tables, key fields, actions, parameters and data fields added, removed
+or changed between two schemas. Each schema is either a bf-rt.json file, "live"
+for the switch of --server, or the address of another switch. The changes which
+break the existing flows are marked with "!", and the command exits with 1
+when there is any.
+
+  bfcli schema diff old/bf-rt.json new/bf-rt.json
+  bfcli schema diff live build/bf-rt.json
+  bfcli schema diff 10.0.0.1:50052 10.0.0.2:50052`,
+	Run: func(cmd *cobra.Command, args []string) {
+		oldInfo, err := loadSchema(args[0])
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+		newInfo, err := loadSchema(args[1])
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		diff := &schemaDiff{}
+		diff.compare(oldInfo, newInfo)
+		if len(diff.lines) == 0 {
+			fmt.Println("The schemas are the same")
+			return
+		}
+		for _, l := range diff.lines {
+			fmt.Println(l)
+		}
+		fmt.Printf("------------------\n%d changes break existing flows\n", diff.breaking)
+		if diff.breaking != 0 {
+			exit(1)
+		}
+	},
+}
+
+// loadSchema reads the P4 schema of a bf-rt.json file, of the --server
+// switch for "live", or of the switch at the address.
+func loadSchema(source string) (*util.BfRtInfoStruct, error) {
+	if source == LIVE_SCHEMA {
+		_, _, conn, cancel, info, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		copied := *info
+		return &copied, nil
+	}
+
+	_, statErr := os.Stat(source)
+	if statErr != nil && !isHostPort(source) {
+		return nil, statErr
+	}
+	if statErr == nil {
+		b, err := ioutil.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		info := &util.BfRtInfoStruct{}
+		if err := json.Unmarshal(b, info); err != nil {
+			// The files saved from a switch are gob encoded.
+			if gob.NewDecoder(bytes.NewReader(b)).Decode(info) != nil {
+				return nil, fmt.Errorf("can not decode %s: %v", source, err)
+			}
+		}
+		return info, nil
+	}
+
+	cli, ctx, conn, cancel := dialAddress(source, SCHEMA_DIAL_TIMEOUT)
+	defer conn.Close()
+	defer cancel()
+	rsp, err := cli.GetForwardingPipelineConfig(ctx, &p4.GetForwardingPipelineConfigRequest{DeviceId: DEVICE_ID})
+	if err != nil {
+		return nil, fmt.Errorf("can not get the pipeline of %s: %v", source, err)
+	}
+	if len(rsp.Config) == 0 {
+		return nil, fmt.Errorf("no program is loaded on %s", source)
+	}
+	info := &util.BfRtInfoStruct{}
+	if err := gob.NewDecoder(bytes.NewReader(rsp.Config[0].BfruntimeInfo)).Decode(info); err != nil {
+		return nil, fmt.Errorf("can not decode the schema of %s: %v", source, err)
+	}
+	return info, nil
+}
+
+// isHostPort reports whether the argument looks like the address of a switch
+// such as 10.0.0.1:50052 or :50052.
+func isHostPort(s string) bool {
+	_, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return false
+	}
+	_, err = strconv.ParseUint(port, 10, 16)
+	return err == nil
+}
+
+func (d *schemaDiff) add(breaking bool, format string, v ...interface{}) {
+	mark := " "
+	if breaking {
+		mark = "!"
+		d.breaking++
+	}
+	d.lines = append(d.lines, mark+" "+fmt.Sprintf(format, v...))
+}
+
+func (d *schemaDiff) compare(oldInfo, newInfo *util.BfRtInfoStruct) {
+	oldTables := map[string]*util.Table{}
+	for i := range oldInfo.Tables {
+		oldTables[oldInfo.Tables[i].Name] = &oldInfo.Tables[i]
+	}
+	newTables := map[string]*util.Table{}
+	var names []string
+	for i := range newInfo.Tables {
+		newTables[newInfo.Tables[i].Name] = &newInfo.Tables[i]
+		names = append(names, newInfo.Tables[i].Name)
+	}
+	for name := range oldTables {
+		if newTables[name] == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldTable, newTable := oldTables[name], newTables[name]
+		switch {
+		case oldTable == nil:
+			d.add(false, "+ table %s (%s, size %d)", name, newTable.TableType, newTable.Size)
+		case newTable == nil:
+			d.add(true, "- table %s (%s, size %d)", name, oldTable.TableType, oldTable.Size)
+		default:
+			d.compareTable(oldTable, newTable)
+		}
+	}
+}
+
+func (d *schemaDiff) compareTable(oldTable, newTable *util.Table) {
+	name := newTable.Name
+	if oldTable.TableType != newTable.TableType {
+		d.add(true, "~ table %s: type %s -> %s", name, oldTable.TableType, newTable.TableType)
+	}
+	if oldTable.Size != newTable.Size {
+		d.add(newTable.Size < oldTable.Size, "~ table %s: size %d -> %d", name, oldTable.Size, newTable.Size)
+	}
+	if !schemaIgnoreIds && oldTable.ID != newTable.ID {
+		d.add(false, "~ table %s: ID %d -> %d", name, oldTable.ID, newTable.ID)
+	}
+
+	d.compareFields(name, "key", keyFields(oldTable), keyFields(newTable))
+
+	oldActions := map[string]actionInfo{}
+	for _, a := range actionSpecs(oldTable) {
+		oldActions[a.Name] = a
+	}
+	newActions := map[string]actionInfo{}
+	var actions []string
+	for _, a := range actionSpecs(newTable) {
+		newActions[a.Name] = a
+		actions = append(actions, a.Name)
+	}
+	for a := range oldActions {
+		if _, ok := newActions[a]; !ok {
+			actions = append(actions, a)
+		}
+	}
+	sort.Strings(actions)
+	for _, a := range actions {
+		oldAction, inOld := oldActions[a]
+		newAction, inNew := newActions[a]
+		switch {
+		case !inOld:
+			d.add(false, "+ table %s: action %s", name, a)
+		case !inNew:
+			d.add(true, "- table %s: action %s", name, a)
+		default:
+			if !schemaIgnoreIds && oldAction.ID != newAction.ID {
+				d.add(false, "~ table %s: action %s ID %d -> %d", name, a, oldAction.ID, newAction.ID)
+			}
+			d.compareFields(name, "action "+a+" parameter", oldAction.Params, newAction.Params)
+		}
+	}
+
+	d.compareFields(name, "data field", dataFields(oldTable), dataFields(newTable))
+}
+
+// compareFields compares the fields of a kind by name. A removed field, a
+// changed match type or type, a narrower width and a new mandatory field
+// break the existing flows.
+func (d *schemaDiff) compareFields(table, kind string, oldFields, newFields []fieldInfo) {
+	oldByName := map[string]fieldInfo{}
+	for _, f := range oldFields {
+		oldByName[f.Name] = f
+	}
+	newByName := map[string]fieldInfo{}
+	var names []string
+	for _, f := range newFields {
+		newByName[f.Name] = f
+		names = append(names, f.Name)
+	}
+	for _, f := range oldFields {
+		if _, ok := newByName[f.Name]; !ok {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		o, inOld := oldByName[n]
+		f, inNew := newByName[n]
+		switch {
+		case !inOld:
+			d.add(f.Mandatory, "+ table %s: %s %s (%s)", table, kind, n, describeField(f))
+		case !inNew:
+			d.add(true, "- table %s: %s %s (%s)", table, kind, n, describeField(o))
+		default:
+			var changes []string
+			breaking := false
+			if o.MatchType != f.MatchType {
+				changes = append(changes, fmt.Sprintf("match type %s -> %s", o.MatchType, f.MatchType))
+				breaking = true
+			}
+			if o.Type != f.Type {
+				changes = append(changes, fmt.Sprintf("type %s -> %s", o.Type, f.Type))
+				breaking = true
+			}
+			if o.Width != f.Width {
+				changes = append(changes, fmt.Sprintf("width %d -> %d", o.Width, f.Width))
+				breaking = breaking || f.Width < o.Width
+			}
+			if o.Repeated != f.Repeated {
+				changes = append(changes, fmt.Sprintf("repeated %t -> %t", o.Repeated, f.Repeated))
+				breaking = true
+			}
+			if o.Mandatory != f.Mandatory {
+				changes = append(changes, fmt.Sprintf("mandatory %t -> %t", o.Mandatory, f.Mandatory))
+				breaking = breaking || f.Mandatory
+			}
+			if !schemaIgnoreIds && o.ID != f.ID {
+				changes = append(changes, fmt.Sprintf("ID %d -> %d", o.ID, f.ID))
+			}
+			if len(changes) != 0 {
+				d.add(breaking, "~ table %s: %s %s: %s", table, kind, n, strings.Join(changes, ", "))
+			}
+		}
+	}
+}
+
+func describeField(f fieldInfo) string {
+	parts := []string{}
+	if f.MatchType != "" {
+		parts = append(parts, f.MatchType)
+	}
+	if f.Type != "" {
+		parts = append(parts, f.Type)
+	}
+	if f.Width != 0 {
+		parts = append(parts, fmt.Sprintf("%d bits", f.Width))
+	}
+	if f.Mandatory {
+		parts = append(parts, "mandatory")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+	schemaCmd.AddCommand(schemaDiffCmd)
+	schemaDiffCmd.Flags().BoolVar(&schemaIgnoreIds, "ignore-ids", false, "Do not report the renumbered IDs")
+}
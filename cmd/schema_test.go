@@ -0,0 +1,134 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/P4Networking/pisc/util"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// testSchema builds a schema from the bf-rt.json tables.
+func testSchema(t *testing.T, tables string) *util.BfRtInfoStruct {
+	t.Helper()
+	info := &util.BfRtInfoStruct{}
+	if err := json.Unmarshal([]byte(`{"tables": [`+tables+`]}`), info); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	return info
+}
+
+func TestSchemaDiffCompare(t *testing.T) {
+	oldInfo := testSchema(t, `
+		{"name": "SwitchIngress.acl", "id": 1, "table_type": "MatchAction_Direct", "size": 512,
+		 "key": [
+			{"id": 1, "name": "dst", "match_type": "Ternary", "mandatory": true, "type": {"type": "bytes", "width": 32}},
+			{"id": 2, "name": "vrf", "match_type": "Exact", "mandatory": true, "type": {"type": "bytes", "width": 8}}
+		 ],
+		 "action_specs": [
+			{"id": 10, "name": "drop", "data": []},
+			{"id": 11, "name": "forward", "data": [{"id": 1, "name": "port", "mandatory": true, "type": {"type": "bytes", "width": 9}}]}
+		 ]},
+		{"name": "SwitchIngress.old", "id": 2, "table_type": "MatchAction_Direct", "size": 16}`)
+	newInfo := testSchema(t, `
+		{"name": "SwitchIngress.acl", "id": 3, "table_type": "MatchAction_Direct", "size": 256,
+		 "key": [
+			{"id": 1, "name": "dst", "match_type": "Ternary", "mandatory": true, "type": {"type": "bytes", "width": 128}},
+			{"id": 3, "name": "proto", "match_type": "Exact", "mandatory": false, "type": {"type": "bytes", "width": 8}}
+		 ],
+		 "action_specs": [
+			{"id": 11, "name": "forward", "data": [{"id": 1, "name": "port", "mandatory": true, "type": {"type": "bytes", "width": 16}}]},
+			{"id": 12, "name": "mirror", "data": []}
+		 ]},
+		{"name": "SwitchIngress.new", "id": 4, "table_type": "Meter", "size": 64}`)
+
+	tests := []struct {
+		ignoreIds bool
+		want      []string
+		breaking  int
+	}{
+		{true, []string{
+			"! ~ table SwitchIngress.acl: size 512 -> 256",
+			"  ~ table SwitchIngress.acl: key dst: width 32 -> 128",
+			"  + table SwitchIngress.acl: key proto (Exact, bytes, 8 bits)",
+			"! - table SwitchIngress.acl: key vrf (Exact, bytes, 8 bits, mandatory)",
+			"! - table SwitchIngress.acl: action drop",
+			"  ~ table SwitchIngress.acl: action forward parameter port: width 9 -> 16",
+			"  + table SwitchIngress.acl: action mirror",
+			"  + table SwitchIngress.new (Meter, size 64)",
+			"! - table SwitchIngress.old (MatchAction_Direct, size 16)",
+		}, 4},
+		{false, []string{
+			"! ~ table SwitchIngress.acl: size 512 -> 256",
+			"  ~ table SwitchIngress.acl: ID 1 -> 3",
+			"  ~ table SwitchIngress.acl: key dst: width 32 -> 128",
+			"  + table SwitchIngress.acl: key proto (Exact, bytes, 8 bits)",
+			"! - table SwitchIngress.acl: key vrf (Exact, bytes, 8 bits, mandatory)",
+			"! - table SwitchIngress.acl: action drop",
+			"  ~ table SwitchIngress.acl: action forward parameter port: width 9 -> 16",
+			"  + table SwitchIngress.acl: action mirror",
+			"  + table SwitchIngress.new (Meter, size 64)",
+			"! - table SwitchIngress.old (MatchAction_Direct, size 16)",
+		}, 4},
+	}
+	defer func() {
+		schemaIgnoreIds = false
+	}()
+	for _, tt := range tests {
+		schemaIgnoreIds = tt.ignoreIds
+		diff := &schemaDiff{}
+		diff.compare(oldInfo, newInfo)
+		if !reflect.DeepEqual(diff.lines, tt.want) || diff.breaking != tt.breaking {
+			t.Errorf("compare with --ignore-ids=%t = %d breaking\n%q\nwant %d breaking\n%q",
+				tt.ignoreIds, diff.breaking, diff.lines, tt.breaking, tt.want)
+		}
+	}
+
+	same := &schemaDiff{}
+	same.compare(oldInfo, oldInfo)
+	if len(same.lines) != 0 {
+		t.Errorf("compare of equal schemas = %q", same.lines)
+	}
+}
+
+func TestLoadSchemaMissingFile(t *testing.T) {
+	// A mistyped path is reported rather than dialled.
+	if _, err := loadSchema("no/such/bf-rt.json"); !os.IsNotExist(err) {
+		t.Errorf("loadSchema of a missing file = %v, want not exist", err)
+	}
+}
+
+func TestIsHostPort(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"10.0.0.1:50052", true},
+		{"switch1:50052", true},
+		{"[::1]:50052", true},
+		{":50052", true},
+		{"bf-rt.json", false},
+		{"build/bf-rt.json", false},
+		{"c:/bf-rt.json", false},
+	}
+	for _, tt := range tests {
+		if got := isHostPort(tt.s); got != tt.want {
+			t.Errorf("isHostPort(%q) = %t, want %t", tt.s, got, tt.want)
+		}
+	}
+}
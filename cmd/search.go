@@ -0,0 +1,204 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/spf13/cobra"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	searchFuzzy bool
+	searchKinds []string
+)
+
+// The kinds of schema items searched.
+var searchKindNames = []string{"table", "key", "action", "param", "data", "annotation"}
+
+// searchHit is a schema item matching the pattern, with where it lives.
+type searchHit struct {
+	kind     string
+	location string
+	score    int
+}
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search PATTERN",
+	Args:  cobra.ExactArgs(1),
+	Short: "Search tables, fields, actions and annotations",
+	Long: `Search the table names, key fields, actions, action parameters, data fields and
+the annotation values of all of them in the P4 and non-P4 schemas, and show
+where each hit lives.
+The pattern is a case-insensitive regular expression, or with --fuzzy the
+characters of the pattern in order, best matches first.
+
+  bfcli search 'ipv4.*dst'
+  bfcli search --fuzzy nhop
+  bfcli search port --kind key,param`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, _, conn, cancel, p4Info, nonP4Info := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+
+		kinds := map[string]bool{}
+		for _, k := range searchKinds {
+			found := false
+			for _, name := range searchKindNames {
+				found = found || k == name
+			}
+			if !found {
+				fmt.Printf("Unknown kind %s, expect one of %s\n", k, strings.Join(searchKindNames, ", "))
+				exit(1)
+			}
+			kinds[k] = true
+		}
+
+		match := func(s string) (int, bool) {
+			return fuzzyScore(args[0], s)
+		}
+		if !searchFuzzy {
+			re, err := regexp.Compile("(?i)" + args[0])
+			if err != nil {
+				fmt.Printf("Invalid pattern: %v\n", err)
+				exit(1)
+			}
+			match = func(s string) (int, bool) {
+				return 0, re.MatchString(s)
+			}
+		}
+
+		hits := searchSchemas([]*util.BfRtInfoStruct{p4Info, nonP4Info}, kinds, match)
+		if len(hits) == 0 {
+			fmt.Printf("Nothing matches %s\n", args[0])
+			return
+		}
+		if searchFuzzy {
+			sort.SliceStable(hits, func(i, j int) bool {
+				return hits[i].score < hits[j].score
+			})
+		}
+		for _, h := range hits {
+			fmt.Printf("%-12s: %s\n", h.kind, h.location)
+		}
+	},
+}
+
+// searchSchemas collects the schema items of the kinds, or of every kind when
+// none is given, whose names match. Annotations match on their values.
+func searchSchemas(infos []*util.BfRtInfoStruct, kinds map[string]bool, match func(string) (int, bool)) []searchHit {
+	var hits []searchHit
+	add := func(kind, name, location string) {
+		if len(kinds) != 0 && !kinds[kind] {
+			return
+		}
+		if score, ok := match(name); ok {
+			hits = append(hits, searchHit{kind: kind, location: location, score: score})
+		}
+	}
+	annotation := func(location, name, value string) {
+		add("annotation", value, fmt.Sprintf("%s %s=%s", location, name, value))
+	}
+	for _, info := range infos {
+		for i := range info.Tables {
+			table := &info.Tables[i]
+			add("table", table.Name, fmt.Sprintf("%s (%s)", table.Name, table.TableType))
+			for _, a := range table.Annotations {
+				annotation(table.Name, a.Name, a.Value)
+			}
+			for _, k := range keyFields(table) {
+				add("key", k.Name, fmt.Sprintf("%s / %s (%s)", table.Name, k.Name, describeField(k)))
+			}
+			for _, k := range table.Key {
+				for _, a := range k.Annotations {
+					annotation(fmt.Sprintf("%s / %s", table.Name, k.Name), a.Name, a.Value)
+				}
+			}
+			for _, a := range actionSpecs(table) {
+				add("action", a.Name, fmt.Sprintf("%s / %s", table.Name, a.Name))
+				for _, p := range a.Params {
+					add("param", p.Name, fmt.Sprintf("%s / %s / %s (%s)", table.Name, a.Name, p.Name, describeField(p)))
+				}
+			}
+			for _, spec := range table.ActionSpecs {
+				for _, a := range spec.Annotations {
+					annotation(fmt.Sprintf("%s / %s", table.Name, spec.Name), a.Name, a.Value)
+				}
+				for _, d := range spec.Data {
+					for _, a := range d.Annotations {
+						annotation(fmt.Sprintf("%s / %s / %s", table.Name, spec.Name, d.Name), a.Name, a.Value)
+					}
+				}
+			}
+			for _, d := range dataFields(table) {
+				add("data", d.Name, fmt.Sprintf("%s / %s (%s)", table.Name, d.Name, describeField(d)))
+			}
+			for _, d := range table.Data {
+				for _, a := range d.Singleton.Annotations {
+					annotation(fmt.Sprintf("%s / %s", table.Name, d.Singleton.Name), a.Name, a.Value)
+				}
+			}
+		}
+	}
+	return hits
+}
+
+// fuzzyScore reports whether the characters of the pattern appear in order
+// in s, ignoring case. The score is lower for tighter matches: the span of
+// the matched characters plus the length of s as a tie breaker.
+func fuzzyScore(pattern, s string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	if len(p) == 0 {
+		return 0, true
+	}
+	text := []rune(strings.ToLower(s))
+	best := -1
+	// Try every start of the first character to find the tightest span.
+	for start := range text {
+		if text[start] != p[0] {
+			continue
+		}
+		j := 1
+		end := start
+		for i := start + 1; i < len(text) && j < len(p); i++ {
+			if text[i] == p[j] {
+				j++
+				end = i
+			}
+		}
+		if j == len(p) && (best < 0 || end-start < best) {
+			best = end - start
+		}
+	}
+	if best < 0 {
+		return 0, false
+	}
+	return best*1000 + utf8.RuneCountInString(s), true
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().BoolVar(&searchFuzzy, "fuzzy", false, "Match the characters of the pattern in order instead of a regular expression")
+	searchCmd.Flags().StringSliceVar(&searchKinds, "kind", nil, "Only search the kinds: table, key, action, param, data, annotation")
+	registerFlagCompletion(searchCmd, "kind", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return searchKindNames, cobra.ShellCompDirectiveNoFileComp
+	})
+}
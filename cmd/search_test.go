@@ -0,0 +1,92 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/P4Networking/pisc/util"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       int
+		ok         bool
+	}{
+		{"nhop", "SwitchIngress.nexthop", 6021, true},
+		{"NHOP", "nhop", 3004, true},
+		{"", "anything", 0, true},
+		{"ipv4", "hdr.ipv4.dst_addr", 3017, true},
+		{"hpon", "nexthop", 0, false},
+		{"x", "nexthop", 7, true},
+		{"xz", "nexthop", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := fuzzyScore(tt.pattern, tt.s)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("fuzzyScore(%q, %q) = %d, %t, want %d, %t", tt.pattern, tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// A tighter match ranks first even in a longer name.
+	tight, _ := fuzzyScore("nhop", "SwitchIngress.nhop_table")
+	loose, _ := fuzzyScore("nhop", "nexthop")
+	if tight >= loose {
+		t.Errorf("fuzzyScore ranks nhop_table %d after nexthop %d", tight, loose)
+	}
+}
+
+func TestSearchSchemas(t *testing.T) {
+	table := testTable(t, `{
+		"name": "SwitchIngress.acl", "table_type": "MatchAction_Direct",
+		"annotations": [{"name": "@name", "value": "acl_table"}],
+		"key": [{"id": 1, "name": "dst", "match_type": "Exact", "annotations": [{"name": "@format", "value": "ipv4_address"}], "type": {"type": "bytes", "width": 32}}],
+		"action_specs": [{"id": 1, "name": "forward", "annotations": [{"name": "@hidden", "value": "forward_hidden"}],
+			"data": [{"id": 1, "name": "port", "annotations": [{"name": "@format", "value": "port_id"}], "type": {"type": "bytes", "width": 9}}]}]
+	}`)
+	info := &util.BfRtInfoStruct{Tables: []util.Table{*table}}
+	tests := []struct {
+		pattern string
+		kinds   map[string]bool
+		want    []string
+	}{
+		{"ipv4", nil, []string{"annotation: SwitchIngress.acl / dst @format=ipv4_address"}},
+		{"hidden", nil, []string{"annotation: SwitchIngress.acl / forward @hidden=forward_hidden"}},
+		{"port", nil, []string{
+			"param: SwitchIngress.acl / forward / port (bytes, 9 bits)",
+			"annotation: SwitchIngress.acl / forward / port @format=port_id",
+		}},
+		{"port", map[string]bool{"param": true}, []string{"param: SwitchIngress.acl / forward / port (bytes, 9 bits)"}},
+		{"acl_table", nil, []string{"annotation: SwitchIngress.acl @name=acl_table"}},
+		// Annotations match on the value, not on the name.
+		{"format", nil, nil},
+	}
+	for _, tt := range tests {
+		re := regexp.MustCompile("(?i)" + tt.pattern)
+		hits := searchSchemas([]*util.BfRtInfoStruct{info}, tt.kinds, func(s string) (int, bool) {
+			return 0, re.MatchString(s)
+		})
+		var got []string
+		for _, h := range hits {
+			got = append(got, h.kind+": "+h.location)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchSchemas(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
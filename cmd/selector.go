@@ -0,0 +1,383 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+const (
+	SELECTOR_TABLE_TYPE  = "Selector"
+	MAX_GROUP_SIZE       = "$MAX_GROUP_SIZE"
+	ACTION_MEMBER_STATUS = "$ACTION_MEMBER_STATUS"
+)
+
+var (
+	selectorGroupId      uint32
+	selectorGroupMaxSize uint32
+	selectorGroupMembers []uint
+)
+
+// selectorGroup is a group of a selector table with the status of each
+// member, true when the member is active.
+type selectorGroup struct {
+	id      uint32
+	maxSize uint64
+	members []uint32
+	status  []bool
+}
+
+// selectorCmd represents the selector command
+var selectorCmd = &cobra.Command{
+	Use:   "selector",
+	Short: "Manage action selectors",
+	Long:  `Manage the groups of action selector tables`,
+}
+
+var selectorGroupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manage selector groups",
+	Long:  `Add, delete or list selector groups and their members`,
+}
+
+var selectorGroupAddCmd = &cobra.Command{
+	Use:   "add SELECTOR-NAME",
+	Args:  cobra.ExactArgs(1),
+	Short: "Add a group into selector",
+	Long: `Add a group into selector with optional initial members, which are all active.
+
+  bfcli selector group add SwitchIngress.nexthop_selector --id 1 --max-size 16 --member 1,2`,
+	ValidArgsFunction: completeTableType(SELECTOR_TABLE_TYPE),
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTypedTable(args[0], SELECTOR_TABLE_TYPE)
+		if table == nil {
+			exit(1)
+		}
+		group := selectorGroup{id: selectorGroupId, maxSize: uint64(selectorGroupMaxSize)}
+		for _, m := range selectorGroupMembers {
+			group.members = append(group.members, uint32(m))
+			group.status = append(group.status, true)
+		}
+		if len(group.members) > int(group.maxSize) {
+			fmt.Printf("The group has %d members, more than max size %d\n", len(group.members), group.maxSize)
+			exit(1)
+		}
+
+		if err := writeSelectorGroup(cli, ctx, table, group, p4.Update_INSERT); err != nil {
+			fatalf("Got error when adding group: %v", err)
+		}
+		fmt.Printf("Group %d is added into %s\n", group.id, table.Name)
+	},
+}
+
+var selectorGroupDelCmd = &cobra.Command{
+	Use:   "del SELECTOR-NAME",
+	Args:  cobra.ExactArgs(1),
+	Short: "Delete a group from selector",
+	Long: `Delete a group from selector. The group must not be referenced by any flow.
+
+  bfcli selector group del SwitchIngress.nexthop_selector --id 1`,
+	ValidArgsFunction: completeTableType(SELECTOR_TABLE_TYPE),
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTypedTable(args[0], SELECTOR_TABLE_TYPE)
+		if table == nil {
+			exit(1)
+		}
+		key, err := buildTableKey(table, []string{fmt.Sprintf("%s=%d", SELECTOR_GROUP_ID, selectorGroupId)})
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		err = writeUpdates(cli, ctx, &p4.Update{
+			Type:   p4.Update_DELETE,
+			Entity: tableEntryEntity(table, key, nil),
+		})
+		if err != nil {
+			fatalf("Got error when deleting group: %v", err)
+		}
+		fmt.Printf("Group %d is deleted from %s\n", selectorGroupId, table.Name)
+	},
+}
+
+var selectorGroupListCmd = &cobra.Command{
+	Use:               "list SELECTOR-NAME",
+	Args:              cobra.ExactArgs(1),
+	Short:             "List the groups of selector",
+	Long:              `List the groups of selector with their members and member status`,
+	ValidArgsFunction: completeTableType(SELECTOR_TABLE_TYPE),
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTypedTable(args[0], SELECTOR_TABLE_TYPE)
+		if table == nil {
+			exit(1)
+		}
+
+		groups, err := readSelectorGroups(cli, ctx, table, nil)
+		if err != nil {
+			fmt.Printf("Got error when reading groups of %s: %v\n", table.Name, err)
+			exit(1)
+		}
+		if len(groups) == 0 {
+			fmt.Printf("The groups in %s is null\n", table.Name)
+			return
+		}
+		for _, g := range groups {
+			fmt.Printf("%-12s: %d\n", "Group ID", g.id)
+			fmt.Printf("%-12s: %d\n", "Max Size", g.maxSize)
+			members := make([]string, 0, len(g.members))
+			for i, m := range g.members {
+				members = append(members, fmt.Sprintf("%d(%s)", m, memberStatus(g, i)))
+			}
+			fmt.Printf("%-12s: %s\n", "Members", strings.Join(members, ", "))
+			fmt.Printf("------------------\n")
+		}
+	},
+}
+
+var selectorGroupAddMemberCmd = &cobra.Command{
+	Use:   "add-member SELECTOR-NAME",
+	Args:  cobra.ExactArgs(1),
+	Short: "Add members into a selector group",
+	Long: `Add active members into a selector group.
+
+  bfcli selector group add-member SwitchIngress.nexthop_selector --id 1 --member 3`,
+	ValidArgsFunction: completeTableType(SELECTOR_TABLE_TYPE),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateSelectorGroupMembers(args[0], func(group *selectorGroup, member uint32) error {
+			for _, m := range group.members {
+				if m == member {
+					return fmt.Errorf("member %d is already in group %d", member, group.id)
+				}
+			}
+			if len(group.members) >= int(group.maxSize) {
+				return fmt.Errorf("group %d is full with max size %d", group.id, group.maxSize)
+			}
+			group.members = append(group.members, member)
+			group.status = append(group.status, true)
+			return nil
+		})
+	},
+}
+
+var selectorGroupDelMemberCmd = &cobra.Command{
+	Use:   "del-member SELECTOR-NAME",
+	Args:  cobra.ExactArgs(1),
+	Short: "Delete members from a selector group",
+	Long: `Delete members from a selector group.
+
+  bfcli selector group del-member SwitchIngress.nexthop_selector --id 1 --member 3`,
+	ValidArgsFunction: completeTableType(SELECTOR_TABLE_TYPE),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateSelectorGroupMembers(args[0], func(group *selectorGroup, member uint32) error {
+			for i, m := range group.members {
+				if m == member {
+					group.members = append(group.members[:i], group.members[i+1:]...)
+					if i < len(group.status) {
+						group.status = append(group.status[:i], group.status[i+1:]...)
+					}
+					return nil
+				}
+			}
+			return fmt.Errorf("member %d is not in group %d", member, group.id)
+		})
+	},
+}
+
+// updateSelectorGroupMembers reads a group, applies update for every member
+// given by --member and writes the group back.
+func updateSelectorGroupMembers(name string, update func(*selectorGroup, uint32) error) {
+	cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+	defer conn.Close()
+	defer cancel()
+	cli := *cliAddr
+	ctx := *ctxAddr
+
+	table := findTypedTable(name, SELECTOR_TABLE_TYPE)
+	if table == nil {
+		exit(1)
+	}
+	if len(selectorGroupMembers) == 0 {
+		fmt.Println("Please specify members by --member")
+		exit(1)
+	}
+
+	id := selectorGroupId
+	groups, err := readSelectorGroups(cli, ctx, table, &id)
+	if err != nil {
+		fmt.Printf("Got error when reading group %d of %s: %v\n", selectorGroupId, table.Name, err)
+		exit(1)
+	}
+	if len(groups) == 0 {
+		fmt.Printf("Can not found group %d in %s\n", selectorGroupId, table.Name)
+		exit(1)
+	}
+	group := groups[0]
+	for _, m := range selectorGroupMembers {
+		if err := update(&group, uint32(m)); err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+	}
+
+	if err := writeSelectorGroup(cli, ctx, table, group, p4.Update_MODIFY); err != nil {
+		fatalf("Got error when modifying group: %v", err)
+	}
+	fmt.Printf("Group %d of %s has members %v\n", group.id, table.Name, group.members)
+}
+
+// readSelectorGroups reads a single group when id is given, or all groups.
+func readSelectorGroups(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table, id *uint32) ([]selectorGroup, error) {
+	var key *p4.TableKey
+	if id != nil {
+		var err error
+		key, err = buildTableKey(table, []string{fmt.Sprintf("%s=%d", SELECTOR_GROUP_ID, *id)})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keyField, _ := searchField(keyFields(table), SELECTOR_GROUP_ID)
+	data := dataFields(table)
+	maxSize, _ := searchField(data, MAX_GROUP_SIZE)
+	members, _ := searchField(data, ACTION_MEMBER_ID)
+	status, _ := searchField(data, ACTION_MEMBER_STATUS)
+
+	entities, err := tryReadEntities(cli, ctx, tableEntryEntity(table, key, nil))
+	if err != nil {
+		return nil, err
+	}
+	var groups []selectorGroup
+	for _, e := range entities {
+		tbl := e.GetTableEntry()
+		if tbl == nil {
+			continue
+		}
+		var group selectorGroup
+		for _, f := range tbl.GetKey().GetFields() {
+			if f.FieldId == keyField.ID {
+				group.id = uint32(decodeValue(f.GetExact().GetValue()).Uint64())
+			}
+		}
+		fields := tbl.GetData().GetFields()
+		if v := collectDataValues(fields, maxSize.ID); len(v) != 0 {
+			group.maxSize = v[0].Uint64()
+		}
+		for _, v := range collectDataValues(fields, members.ID) {
+			group.members = append(group.members, uint32(v.Uint64()))
+		}
+		for _, v := range collectDataValues(fields, status.ID) {
+			group.status = append(group.status, v.Sign() != 0)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func writeSelectorGroup(cli p4.BfRuntimeClient, ctx context.Context, table *util.Table, group selectorGroup, updateType p4.Update_Type) error {
+	key, err := buildTableKey(table, []string{fmt.Sprintf("%s=%d", SELECTOR_GROUP_ID, group.id)})
+	if err != nil {
+		return err
+	}
+	data, err := selectorGroupData(table, group)
+	if err != nil {
+		return err
+	}
+
+	return writeUpdates(cli, ctx, &p4.Update{
+		Type:   updateType,
+		Entity: tableEntryEntity(table, key, data),
+	})
+}
+
+// selectorGroupData encodes the size and the members of a group. The member
+// fields are sent even when empty, so deleting the last member clears them.
+func selectorGroupData(table *util.Table, group selectorGroup) (*p4.TableData, error) {
+	data, err := buildTableData(table, []string{fmt.Sprintf("%s=%d", MAX_GROUP_SIZE, group.maxSize)})
+	if err != nil {
+		return nil, err
+	}
+	fields := dataFields(table)
+	members, ok := searchField(fields, ACTION_MEMBER_ID)
+	if !ok {
+		return nil, fmt.Errorf("can not found data field %s in table %s", ACTION_MEMBER_ID, table.Name)
+	}
+	status, ok := searchField(fields, ACTION_MEMBER_STATUS)
+	if !ok {
+		return nil, fmt.Errorf("can not found data field %s in table %s", ACTION_MEMBER_STATUS, table.Name)
+	}
+
+	ids := make([]uint32, 0, len(group.members))
+	active := make([]bool, 0, len(group.members))
+	for i, m := range group.members {
+		ids = append(ids, m)
+		active = append(active, i >= len(group.status) || group.status[i])
+	}
+	data.Fields = append(data.Fields,
+		&p4.DataField{FieldId: members.ID, Value: &p4.DataField_IntArrVal{IntArrVal: &p4.DataField_IntArray{Val: ids}}},
+		&p4.DataField{FieldId: status.ID, Value: &p4.DataField_BoolArrVal{BoolArrVal: &p4.DataField_BoolArray{Val: active}}})
+	return data, nil
+}
+
+// memberStatus describes the i-th member of a group, members without a
+// reported status are active.
+func memberStatus(group selectorGroup, i int) string {
+	if i < len(group.status) && !group.status[i] {
+		return "inactive"
+	}
+	return "active"
+}
+
+func init() {
+	rootCmd.AddCommand(selectorCmd)
+	selectorCmd.AddCommand(selectorGroupCmd)
+	selectorGroupCmd.AddCommand(selectorGroupAddCmd)
+	selectorGroupCmd.AddCommand(selectorGroupDelCmd)
+	selectorGroupCmd.AddCommand(selectorGroupListCmd)
+	selectorGroupCmd.AddCommand(selectorGroupAddMemberCmd)
+	selectorGroupCmd.AddCommand(selectorGroupDelMemberCmd)
+
+	for _, c := range []*cobra.Command{selectorGroupAddCmd, selectorGroupDelCmd, selectorGroupAddMemberCmd, selectorGroupDelMemberCmd} {
+		c.Flags().Uint32Var(&selectorGroupId, "id", 0, "The group ID")
+		c.MarkFlagRequired("id")
+	}
+	for _, c := range []*cobra.Command{selectorGroupAddCmd, selectorGroupAddMemberCmd, selectorGroupDelMemberCmd} {
+		c.Flags().UintSliceVar(&selectorGroupMembers, "member", nil, "The action profile member IDs, e.g. 1,2,3")
+	}
+	selectorGroupAddCmd.Flags().Uint32Var(&selectorGroupMaxSize, "max-size", 16, "The max number of members in the group")
+}
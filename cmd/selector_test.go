@@ -0,0 +1,76 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testSelectorTable = `{
+	"name": "SwitchIngress.ecmp_selector", "id": 3, "table_type": "Selector", "size": 16,
+	"key": [
+		{"id": 1, "name": "$SELECTOR_GROUP_ID", "match_type": "Exact", "mandatory": true, "type": {"type": "uint32"}}
+	],
+	"data": [
+		{"mandatory": false, "singleton": {"id": 1, "name": "$ACTION_MEMBER_ID", "repeated": true, "type": {"type": "uint32"}}},
+		{"mandatory": false, "singleton": {"id": 2, "name": "$ACTION_MEMBER_STATUS", "repeated": true, "type": {"type": "bool"}}},
+		{"mandatory": false, "singleton": {"id": 3, "name": "$MAX_GROUP_SIZE", "type": {"type": "uint32", "width": 32}}}
+	]
+}`
+
+func TestSelectorGroupData(t *testing.T) {
+	table := testTable(t, testSelectorTable)
+	tests := []struct {
+		name    string
+		group   selectorGroup
+		members []uint32
+		status  []bool
+	}{
+		{"members", selectorGroup{id: 1, maxSize: 4, members: []uint32{10, 11}, status: []bool{true, false}}, []uint32{10, 11}, []bool{true, false}},
+		{"no status", selectorGroup{id: 1, maxSize: 4, members: []uint32{10}}, []uint32{10}, []bool{true}},
+		{"last member removed", selectorGroup{id: 1, maxSize: 4}, []uint32{}, []bool{}},
+	}
+	for _, tt := range tests {
+		data, err := selectorGroupData(table, tt.group)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		var members []uint32
+		var status []bool
+		found := map[uint32]bool{}
+		for _, f := range data.GetFields() {
+			found[f.FieldId] = true
+			switch f.FieldId {
+			case 1:
+				members = f.GetIntArrVal().GetVal()
+			case 2:
+				status = f.GetBoolArrVal().GetVal()
+			}
+		}
+		if !found[1] || !found[2] || !found[3] {
+			t.Errorf("%s: sent fields %v, want the size and both member fields", tt.name, found)
+			continue
+		}
+		if len(members) != len(tt.members) || (len(members) != 0 && !reflect.DeepEqual(members, tt.members)) {
+			t.Errorf("%s: members = %v, want %v", tt.name, members, tt.members)
+		}
+		if len(status) != len(tt.status) || (len(status) != 0 && !reflect.DeepEqual(status, tt.status)) {
+			t.Errorf("%s: status = %v, want %v", tt.name, status, tt.status)
+		}
+	}
+}
@@ -17,27 +17,103 @@ package cmd
 
 import (
 	"fmt"
-
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
 	"github.com/spf13/cobra"
 )
 
+const (
+	ACTION_MEMBER_ID  = "$ACTION_MEMBER_ID"
+	SELECTOR_GROUP_ID = "$SELECTOR_GROUP_ID"
+)
+
+var (
+	setFlowAction string
+	setFlowParams []string
+	setFlowMember uint32
+	setFlowGroup  uint32
+)
+
 // setFlowCmd represents the setFlow command
 var setFlowCmd = &cobra.Command{
-	Use:   "set-flow",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Use:   "set-flow TABLE-NAME KEY=VALUE...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Add a flow into specify table",
+	Long: `Add a flow with the given match key into specify table. The flow either runs
+an action with parameters, or references an action profile member or a
+selector group when the table uses an action profile.
+
+  bfcli set-flow SwitchIngress.ipv4_lpm ipv4_dst=10.0.0.0/8 --action set_nexthop -p port=1
+  bfcli set-flow SwitchIngress.ipv4_ecmp ipv4_dst=10.0.0.0/8 --group 1
+  bfcli set-flow SwitchIngress.ipv4_ecmp ipv4_dst=10.1.0.0/16 --member 3`,
+	ValidArgsFunction: completeTableArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("setFlow called")
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTable(args[0])
+		if table == nil {
+			fmt.Printf("Can not found table with name: %s\n", args[0])
+			exit(1)
+		}
+
+		key, err := buildTableKey(table, args[1:])
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		data, err := buildFlowData(cmd, table)
+		if err != nil {
+			fmt.Println(err)
+			exit(1)
+		}
+
+		err = writeUpdates(cli, ctx, &p4.Update{
+			Type:   p4.Update_INSERT,
+			Entity: tableEntryEntity(table, key, data),
+		})
+		if err != nil {
+			fatalf("Got error when adding flow: %v", err)
+		}
+		fmt.Printf("The flow is added into %s\n", table.Name)
 	},
 }
 
+// buildFlowData builds the data of a flow from --action and --param, --member
+// or --group.
+func buildFlowData(cmd *cobra.Command, table *util.Table) (*p4.TableData, error) {
+	chosen := 0
+	for _, flag := range []string{"action", "member", "group"} {
+		if cmd.Flags().Changed(flag) {
+			chosen++
+		}
+	}
+	if chosen != 1 {
+		return nil, fmt.Errorf("please specify exactly one of --action, --member and --group")
+	}
+
+	switch {
+	case cmd.Flags().Changed("member"):
+		return buildTableData(table, []string{fmt.Sprintf("%s=%d", ACTION_MEMBER_ID, setFlowMember)})
+	case cmd.Flags().Changed("group"):
+		return buildTableData(table, []string{fmt.Sprintf("%s=%d", SELECTOR_GROUP_ID, setFlowGroup)})
+	default:
+		return buildActionData(table, setFlowAction, setFlowParams)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(setFlowCmd)
+	setFlowCmd.Flags().StringVarP(&setFlowAction, "action", "a", "", "The action of the flow")
+	setFlowCmd.Flags().StringArrayVarP(&setFlowParams, "param", "p", nil, "The action parameter NAME=VALUE, can be repeated")
+	setFlowCmd.Flags().Uint32Var(&setFlowMember, "member", 0, "The action profile member ID referenced by the flow")
+	setFlowCmd.Flags().Uint32Var(&setFlowGroup, "group", 0, "The selector group ID referenced by the flow")
+	registerFlagCompletion(setFlowCmd, "action", completeActionFlag)
+	registerFlagCompletion(setFlowCmd, "param", completeParamFlag)
 
 	// Here you will define your flags and configuration settings.
 
@@ -0,0 +1,356 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/chzyer/readline"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// session is the connection shared by the commands run in the shell.
+type session struct {
+	cli    p4.BfRuntimeClient
+	ctx    context.Context
+	conn   io.Closer
+	cancel context.CancelFunc
+}
+
+// shellSession is set while commands run inside the shell.
+var shellSession *session
+
+// shellExit is raised by fatalf and exit inside the shell to abort the
+// running command.
+type shellExit struct {
+	code int
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// shellCmd represents the shell command
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Args:  cobra.ExactArgs(0),
+	Short: "Start an interactive shell",
+	Long: `Start an interactive shell which connects to the server once and runs bfcli
+commands without the bfcli prefix. Tab completes commands, table names, key
+fields, actions and action parameters. Type exit or press Ctrl+D to leave.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		startSession()
+		defer closeSession()
+
+		historyFile := ""
+		if home, err := homedir.Dir(); err == nil {
+			historyFile = filepath.Join(home, ".bfcli_history")
+		}
+		rl, err := readline.NewEx(&readline.Config{
+			Prompt:          "bfcli> ",
+			HistoryFile:     historyFile,
+			AutoComplete:    shellCompleter{},
+			InterruptPrompt: "^C",
+			EOFPrompt:       "exit",
+		})
+		if err != nil {
+			fatalf("Can not start the shell: %v", err)
+		}
+		defer rl.Close()
+
+		for {
+			line, err := rl.Readline()
+			if err == readline.ErrInterrupt {
+				continue
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				fatalf("Got error: %v", err)
+			}
+
+			words, err := splitWords(line)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if len(words) == 0 {
+				continue
+			}
+			switch words[0] {
+			case "exit", "quit":
+				return
+			case "shell":
+				fmt.Println("Already in the shell")
+				continue
+			}
+			runShellCommand(words)
+		}
+	},
+}
+
+// startSession connects to the server and keeps the connection for the
+// following commands.
+func startSession() {
+	cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+	shellSession = &session{cli: *cliAddr, ctx: *ctxAddr, conn: conn, cancel: cancel}
+}
+
+func closeSession() {
+	if shellSession == nil {
+		return
+	}
+	shellSession.cancel()
+	shellSession.conn.Close()
+	shellSession = nil
+}
+
+// runShellCommand runs a bfcli command within the session and returns its
+// exit code. Ctrl+C cancels the running command instead of the shell.
+func runShellCommand(args []string) (code int) {
+	parent := shellSession.ctx
+	ctx, cancel := context.WithCancel(parent)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	shellSession.ctx = ctx
+	bfrtCtx = ctx
+
+	defer func() {
+		signal.Stop(interrupt)
+		cancel()
+		shellSession.ctx = parent
+		bfrtCtx = parent
+		if r := recover(); r != nil {
+			e, ok := r.(shellExit)
+			if !ok {
+				panic(r)
+			}
+			code = e.code
+		}
+	}()
+
+	resetFlags(rootCmd)
+	rootCmd.SetArgs(args)
+	if err := rootCmd.Execute(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// resetFlags restores the flags of every command to their defaults, since
+// cobra keeps the values of the previous run. The persistent flags of the
+// root command such as --server are kept.
+func resetFlags(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if rootCmd.PersistentFlags().Lookup(f.Name) != nil {
+			return
+		}
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			s.Replace(sliceDefault(f.DefValue))
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+// sliceDefault splits the default of a slice flag such as "[0,1,2,3]" into
+// its values, since Set would append to the slice instead of replacing it.
+func sliceDefault(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// splitWords splits a command line into words like a shell, honouring single
+// and double quotes and backslash escapes.
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", line)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// shellCompleter completes the shell input from the command tree and the
+// BfRtInfo of the connected device.
+type shellCompleter struct{}
+
+func (shellCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	input := string(line[:pos])
+	words, err := splitWords(input)
+	if err != nil {
+		return nil, 0
+	}
+	partial := ""
+	if len(words) != 0 && !strings.HasSuffix(input, " ") {
+		partial = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	var candidates [][]rune
+	for _, c := range completeWords(words, partial) {
+		if strings.HasPrefix(c, partial) {
+			candidates = append(candidates, []rune(c[len(partial):]))
+		}
+	}
+	return candidates, len([]rune(partial))
+}
+
+// completeWords lists the candidates for the word after words.
+func completeWords(words []string, partial string) []string {
+	cmd, rest, err := rootCmd.Find(words)
+	if err != nil {
+		return nil
+	}
+
+	if strings.HasPrefix(partial, "-") {
+		var names []string
+		visit := func(f *pflag.Flag) {
+			names = append(names, "--"+f.Name)
+		}
+		cmd.Flags().VisitAll(visit)
+		cmd.InheritedFlags().VisitAll(visit)
+		return names
+	}
+
+	positionals, pending := splitFlagArgs(cmd, rest)
+	var complete completionFunc
+	if pending != nil {
+		complete = flagCompletions[pending]
+		// Parse the flags given so far, --param completion depends on --action.
+		cmd.ParseFlags(rest[:len(rest)-1])
+	} else if len(positionals) == 0 && cmd.HasAvailableSubCommands() {
+		var names []string
+		for _, c := range cmd.Commands() {
+			if c.IsAvailableCommand() {
+				names = append(names, c.Name())
+			}
+		}
+		return names
+	} else {
+		complete = cmd.ValidArgsFunction
+	}
+	if complete == nil {
+		return nil
+	}
+	names, _ := complete(cmd, positionals, partial)
+	// Drop the descriptions meant for the shell completion scripts.
+	for i, name := range names {
+		if j := strings.Index(name, "\t"); j >= 0 {
+			names[i] = name[:j]
+		}
+	}
+	return names
+}
+
+// splitFlagArgs separates positional arguments from flags, and returns
+// the flag still waiting for its value at the end of args.
+func splitFlagArgs(cmd *cobra.Command, args []string) ([]string, *pflag.Flag) {
+	lookup := func(name string) *pflag.Flag {
+		for _, flags := range []*pflag.FlagSet{cmd.Flags(), cmd.InheritedFlags()} {
+			if f := flags.Lookup(name); f != nil {
+				return f
+			}
+			if len(name) == 1 {
+				if f := flags.ShorthandLookup(name); f != nil {
+					return f
+				}
+			}
+		}
+		return nil
+	}
+
+	var positionals []string
+	var pending *pflag.Flag
+	for _, arg := range args {
+		if pending != nil {
+			pending = nil
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positionals = append(positionals, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if f := lookup(name); f != nil && f.NoOptDefVal == "" {
+			pending = f
+		}
+	}
+	return positionals, pending
+}
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+}
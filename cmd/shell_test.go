@@ -0,0 +1,83 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"reflect"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"  dump  SwitchIngress.acl ", []string{"dump", "SwitchIngress.acl"}, false},
+		{`set-flow t -a "act one" k=1`, []string{"set-flow", "t", "-a", "act one", "k=1"}, false},
+		{`echo 'a "b" \c'`, []string{"echo", `a "b" \c`}, false},
+		{`echo "a \"b\""`, []string{"echo", `a "b"`}, false},
+		{`a\ b c`, []string{"a b", "c"}, false},
+		{`""`, []string{""}, false},
+		{`x=""y`, []string{"x=y"}, false},
+		{`"unterminated`, nil, true},
+		{`trailing\`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := splitWords(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitWords(%q) error = %v, want error %t", tt.line, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitWords(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestResetFlagsRestoresSliceDefaults(t *testing.T) {
+	withTestSession(t)
+	var pipes []uint
+	var names []string
+	cmd := &cobra.Command{
+		Use: "test-reset-flags",
+		Run: func(cmd *cobra.Command, args []string) {},
+	}
+	cmd.Flags().UintSliceVar(&pipes, "pipes", []uint{0, 1, 2, 3}, "")
+	cmd.Flags().StringSliceVar(&names, "names", nil, "")
+	rootCmd.AddCommand(cmd)
+	t.Cleanup(func() {
+		rootCmd.RemoveCommand(cmd)
+	})
+
+	if code := runShellCommand([]string{"test-reset-flags", "--pipes", "1", "--names", "a,b"}); code != 0 {
+		t.Fatalf("first run exited with %d", code)
+	}
+	if !reflect.DeepEqual(pipes, []uint{1}) || !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Fatalf("first run got pipes %v names %v", pipes, names)
+	}
+	if code := runShellCommand([]string{"test-reset-flags"}); code != 0 {
+		t.Fatalf("second run exited with %d", code)
+	}
+	if !reflect.DeepEqual(pipes, []uint{0, 1, 2, 3}) {
+		t.Errorf("second run got pipes %v, want the default [0 1 2 3]", pipes)
+	}
+	if len(names) != 0 {
+		t.Errorf("second run got names %v, want none", names)
+	}
+}
@@ -0,0 +1,573 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/gdamore/tcell/v2"
+	"github.com/spf13/cobra"
+	"sort"
+	"strings"
+)
+
+// The panes of the terminal UI in the order of Tab.
+const (
+	tablesPane = iota
+	schemaPane
+	entriesPane
+	paneCount
+)
+
+const tuiHelp = "Tab: pane  Enter: open  /: search  s: sort  r: reload  e: edit  d: delete  q: quit"
+
+// tuiList is a scrollable list of lines, with a selected line when it is
+// selectable.
+type tuiList struct {
+	lines      []string
+	sel, top   int
+	height     int
+	selectable bool
+}
+
+// tuiEntry is a flow shown in the entries pane.
+type tuiEntry struct {
+	key   string
+	data  string
+	entry *p4.TableEntry
+}
+
+// tuiPrompt reads a line, or a single key when confirm is set, on the status
+// line.
+type tuiPrompt struct {
+	label   string
+	text    []rune
+	confirm bool
+	done    func(string)
+}
+
+type tui struct {
+	screen tcell.Screen
+	cli    p4.BfRuntimeClient
+	ctx    context.Context
+
+	all         []*util.Table
+	tables      []*util.Table
+	tableFilter string
+	table       *util.Table
+	loaded      []tuiEntry
+	entries     []tuiEntry
+	entryFilter string
+	sortByData  bool
+
+	lists  [paneCount]*tuiList
+	focus  int
+	prompt *tuiPrompt
+	status string
+	quit   bool
+}
+
+// tuiCmd represents the tui command
+var tuiCmd = &cobra.Command{
+	Use:   "tui [TABLE-NAME]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "Browse tables and flows in a terminal UI",
+	Long: `Browse the P4 and non-P4 tables in a full-screen terminal UI. The left pane
+lists the tables, the right panes show the schema and the flows of the opened
+table. The flows can be searched, sorted by key or action, edited and deleted.
+
+  Tab, Shift+Tab   switch pane
+  Up, Down, j, k   move, PgUp, PgDn, Home and End scroll by page
+  Enter            open the selected table
+  /                search the tables or flows, Esc clears the search
+  s                sort the flows by key or by action
+  r                read the flows again
+  e                edit the action and parameters of the selected flow
+  d                delete the selected flow
+  q, Ctrl+C        quit`,
+	ValidArgsFunction: completeTableName,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, p4Info, nonP4Info := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+
+		screen, err := tcell.NewScreen()
+		if err != nil {
+			fatalf("Can not start the terminal UI: %v", err)
+		}
+		if err := screen.Init(); err != nil {
+			fatalf("Can not start the terminal UI: %v", err)
+		}
+		defer screen.Fini()
+
+		t := &tui{screen: screen, cli: *cliAddr, ctx: *ctxAddr}
+		for _, info := range []*util.BfRtInfoStruct{p4Info, nonP4Info} {
+			for i := range info.Tables {
+				t.all = append(t.all, &info.Tables[i])
+			}
+		}
+		sort.Slice(t.all, func(i, j int) bool {
+			return t.all[i].Name < t.all[j].Name
+		})
+		t.lists = [paneCount]*tuiList{{selectable: true}, {}, {selectable: true}}
+		t.filterTables()
+		t.status = tuiHelp
+		if len(args) == 1 {
+			if table := findTable(args[0]); table != nil {
+				t.openTable(table)
+			} else {
+				t.status = fmt.Sprintf("Can not found table with name: %s", args[0])
+			}
+		}
+		t.run()
+	},
+}
+
+func (t *tui) run() {
+	for !t.quit {
+		t.draw()
+		switch ev := t.screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			t.screen.Sync()
+		case *tcell.EventKey:
+			if t.prompt != nil {
+				t.handlePrompt(ev)
+			} else {
+				t.handleKey(ev)
+			}
+		}
+	}
+}
+
+func (t *tui) handleKey(ev *tcell.EventKey) {
+	t.status = tuiHelp
+	list := t.lists[t.focus]
+	switch ev.Key() {
+	case tcell.KeyCtrlC:
+		t.quit = true
+	case tcell.KeyTab:
+		t.focus = (t.focus + 1) % paneCount
+	case tcell.KeyBacktab:
+		t.focus = (t.focus + paneCount - 1) % paneCount
+	case tcell.KeyUp:
+		list.move(-1)
+	case tcell.KeyDown:
+		list.move(1)
+	case tcell.KeyPgUp:
+		list.move(-list.height)
+	case tcell.KeyPgDn:
+		list.move(list.height)
+	case tcell.KeyHome:
+		list.move(-len(list.lines))
+	case tcell.KeyEnd:
+		list.move(len(list.lines))
+	case tcell.KeyEnter:
+		if t.focus == tablesPane && len(t.tables) != 0 {
+			t.openTable(t.tables[list.sel])
+			t.focus = entriesPane
+		}
+	case tcell.KeyEscape:
+		switch t.focus {
+		case tablesPane:
+			t.tableFilter = ""
+			t.filterTables()
+		case entriesPane:
+			t.entryFilter = ""
+			t.filterEntries()
+		}
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q':
+			t.quit = true
+		case 'k':
+			list.move(-1)
+		case 'j':
+			list.move(1)
+		case '/':
+			t.search()
+		case 's':
+			t.sortByData = !t.sortByData
+			t.filterEntries()
+		case 'r':
+			if t.table != nil {
+				t.loadEntries()
+			}
+		case 'e':
+			t.editEntry()
+		case 'd':
+			t.deleteEntry()
+		}
+	}
+}
+
+func (t *tui) handlePrompt(ev *tcell.EventKey) {
+	p := t.prompt
+	if p.confirm {
+		t.prompt = nil
+		if ev.Key() == tcell.KeyRune && (ev.Rune() == 'y' || ev.Rune() == 'Y') {
+			p.done("y")
+		} else {
+			t.status = tuiHelp
+		}
+		return
+	}
+	switch ev.Key() {
+	case tcell.KeyEscape, tcell.KeyCtrlC:
+		t.prompt = nil
+		t.status = tuiHelp
+	case tcell.KeyEnter:
+		t.prompt = nil
+		t.status = tuiHelp
+		p.done(string(p.text))
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(p.text) != 0 {
+			p.text = p.text[:len(p.text)-1]
+		}
+	case tcell.KeyCtrlU:
+		p.text = nil
+	case tcell.KeyRune:
+		p.text = append(p.text, ev.Rune())
+	}
+}
+
+func (t *tui) search() {
+	switch t.focus {
+	case tablesPane:
+		t.prompt = &tuiPrompt{label: "Search tables: ", text: []rune(t.tableFilter), done: func(s string) {
+			t.tableFilter = s
+			t.filterTables()
+		}}
+	case entriesPane:
+		t.prompt = &tuiPrompt{label: "Search flows: ", text: []rune(t.entryFilter), done: func(s string) {
+			t.entryFilter = s
+			t.filterEntries()
+		}}
+	}
+}
+
+func (t *tui) filterTables() {
+	filter := strings.ToLower(t.tableFilter)
+	t.tables = t.tables[:0]
+	var lines []string
+	for _, table := range t.all {
+		if strings.Contains(strings.ToLower(table.Name), filter) {
+			t.tables = append(t.tables, table)
+			lines = append(lines, fmt.Sprintf("%s [%s]", table.Name, table.TableType))
+		}
+	}
+	t.lists[tablesPane].set(lines)
+}
+
+func (t *tui) openTable(table *util.Table) {
+	t.table = table
+	t.entryFilter = ""
+	t.lists[schemaPane].top = 0
+	t.lists[schemaPane].set(tableSchemaLines(table))
+	t.lists[entriesPane].sel = 0
+	t.loadEntries()
+}
+
+func (t *tui) loadEntries() {
+	entities, err := tryReadEntities(t.cli, t.ctx, tableEntryEntity(t.table, nil, nil))
+	if err != nil {
+		t.status = fmt.Sprintf("Got error when reading %s: %v", t.table.Name, err)
+		entities = nil
+	}
+	t.loaded = t.loaded[:0]
+	for _, e := range entities {
+		tbl := e.GetTableEntry()
+		if tbl == nil {
+			continue
+		}
+		t.loaded = append(t.loaded, tuiEntry{
+			key:   formatTableKey(t.table, tbl.GetKey()),
+			data:  formatEntryData(t.table, tbl.GetData()),
+			entry: tbl,
+		})
+	}
+	t.filterEntries()
+}
+
+func (t *tui) filterEntries() {
+	filter := strings.ToLower(t.entryFilter)
+	t.entries = t.entries[:0]
+	for _, e := range t.loaded {
+		if strings.Contains(strings.ToLower(e.key+" "+e.data), filter) {
+			t.entries = append(t.entries, e)
+		}
+	}
+	sort.SliceStable(t.entries, func(i, j int) bool {
+		a, b := t.entries[i], t.entries[j]
+		if t.sortByData && a.data != b.data {
+			return a.data < b.data
+		}
+		return a.key < b.key
+	})
+	lines := make([]string, 0, len(t.entries))
+	for _, e := range t.entries {
+		lines = append(lines, e.key+"  ->  "+e.data)
+	}
+	t.lists[entriesPane].set(lines)
+}
+
+// selectedEntry returns the flow under the cursor when the entries pane has
+// the focus.
+func (t *tui) selectedEntry() *tuiEntry {
+	if t.focus != entriesPane || len(t.entries) == 0 {
+		return nil
+	}
+	return &t.entries[t.lists[entriesPane].sel]
+}
+
+func (t *tui) deleteEntry() {
+	selected := t.selectedEntry()
+	if selected == nil {
+		return
+	}
+	e := *selected
+	if len(e.entry.GetKey().GetFields()) == 0 {
+		t.status = "The default flow can not be deleted"
+		return
+	}
+	t.prompt = &tuiPrompt{label: fmt.Sprintf("Delete %s? (y/n) ", e.key), confirm: true, done: func(string) {
+		err := writeUpdates(t.cli, t.ctx, &p4.Update{
+			Type:   p4.Update_DELETE,
+			Entity: tableEntryEntity(t.table, e.entry.GetKey(), nil),
+		})
+		t.loadEntries()
+		if err != nil {
+			t.status = fmt.Sprintf("Got error when deleting flow: %v", err)
+			return
+		}
+		t.status = fmt.Sprintf("The flow %s is deleted", e.key)
+	}}
+}
+
+func (t *tui) editEntry() {
+	selected := t.selectedEntry()
+	if selected == nil {
+		return
+	}
+	e := *selected
+	t.prompt = &tuiPrompt{label: "Edit: ", text: []rune(entryEditText(t.table, e.entry.GetData())), done: func(s string) {
+		words, err := splitWords(s)
+		if err != nil {
+			t.status = err.Error()
+			return
+		}
+		data, err := buildEntryData(t.table, words)
+		if err != nil {
+			t.status = err.Error()
+			return
+		}
+		entity := tableEntryEntity(t.table, e.entry.GetKey(), data)
+		if len(e.entry.GetKey().GetFields()) == 0 {
+			entity.GetTableEntry().IsDefaultEntry = true
+		}
+		err = writeUpdates(t.cli, t.ctx, &p4.Update{Type: p4.Update_MODIFY, Entity: entity})
+		t.loadEntries()
+		if err != nil {
+			t.status = fmt.Sprintf("Got error when modifying flow: %v", err)
+			return
+		}
+		t.status = fmt.Sprintf("The flow %s is modified", e.key)
+	}}
+}
+
+// entryEditText renders the data of a flow as "ACTION NAME=VALUE...", the
+// syntax taken back by the edit prompt.
+func entryEditText(table *util.Table, data *p4.TableData) string {
+	var parts []string
+	if a, ok := searchActionById(table, data.GetActionId()); ok {
+		parts = append(parts, a.Name)
+	}
+	for _, d := range data.GetFields() {
+		name := dataFieldName(table, data.GetActionId(), d.FieldId)
+		if isVolatileField(name) {
+			continue
+		}
+		value := strings.Join(strings.Fields(formatDataField(d)), ",")
+		parts = append(parts, name+"="+value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// tableSchemaLines describes a table like the info command.
+func tableSchemaLines(table *util.Table) []string {
+	lines := []string{
+		fmt.Sprintf("%-12s: %s", "Table Name", table.Name),
+		fmt.Sprintf("%-12s: %d", "Table ID", table.ID),
+		fmt.Sprintf("%-12s: %s", "Table Type", table.TableType),
+		fmt.Sprintf("%-12s: %d", "Table Size", table.Size),
+	}
+	if keys := keyFields(table); len(keys) != 0 {
+		lines = append(lines, "", "Table Key:")
+		for _, k := range keys {
+			lines = append(lines, fmt.Sprintf("  %-24s %-8s %4d bits  mandatory: %t", k.Name, k.MatchType, k.Width, k.Mandatory))
+		}
+	}
+	if actions := actionSpecs(table); len(actions) != 0 {
+		lines = append(lines, "", "Action Specs:")
+		for _, a := range actions {
+			lines = append(lines, "  "+a.Name)
+			for _, p := range a.Params {
+				lines = append(lines, fmt.Sprintf("    %-22s %-8s %4d bits", p.Name, p.Type, p.Width))
+			}
+		}
+	}
+	if data := dataFields(table); len(data) != 0 {
+		lines = append(lines, "", "Table Data:")
+		for _, d := range data {
+			lines = append(lines, fmt.Sprintf("  %-24s %-8s %4d bits  repeated: %t", d.Name, d.Type, d.Width, d.Repeated))
+		}
+	}
+	return lines
+}
+
+func (t *tui) draw() {
+	s := t.screen
+	s.Clear()
+	w, h := s.Size()
+	left := w / 3
+	top := (h - 1) * 2 / 5
+
+	tablesTitle := fmt.Sprintf("Tables (%d/%d)", len(t.tables), len(t.all))
+	if t.tableFilter != "" {
+		tablesTitle += " /" + t.tableFilter
+	}
+	t.lists[tablesPane].draw(s, 0, 0, left, h-1, tablesTitle, t.focus == tablesPane)
+
+	schemaTitle, entriesTitle := "Schema", "Flows"
+	if t.table != nil {
+		schemaTitle = "Schema of " + t.table.Name
+		entriesTitle = fmt.Sprintf("Flows (%d/%d)", len(t.entries), len(t.loaded))
+		if t.sortByData {
+			entriesTitle += " by action"
+		} else {
+			entriesTitle += " by key"
+		}
+		if t.entryFilter != "" {
+			entriesTitle += " /" + t.entryFilter
+		}
+	}
+	t.lists[schemaPane].draw(s, left, 0, w-left, top, schemaTitle, t.focus == schemaPane)
+	t.lists[entriesPane].draw(s, left, top, w-left, h-1-top, entriesTitle, t.focus == entriesPane)
+
+	if p := t.prompt; p != nil {
+		line := p.label + string(p.text)
+		drawText(s, 0, h-1, w, tcell.StyleDefault, line)
+		if !p.confirm {
+			s.ShowCursor(len([]rune(line)), h-1)
+		}
+	} else {
+		s.HideCursor()
+		drawText(s, 0, h-1, w, tcell.StyleDefault.Reverse(true), t.status+strings.Repeat(" ", w))
+	}
+	s.Show()
+}
+
+func (l *tuiList) set(lines []string) {
+	l.lines = lines
+	l.clamp()
+}
+
+// move moves the selection, or scrolls when the list is not selectable.
+func (l *tuiList) move(delta int) {
+	if l.selectable {
+		l.sel += delta
+	} else {
+		l.top += delta
+	}
+	l.clamp()
+}
+
+func (l *tuiList) clamp() {
+	n := len(l.lines)
+	if l.selectable {
+		if l.sel >= n {
+			l.sel = n - 1
+		}
+		if l.sel < 0 {
+			l.sel = 0
+		}
+		if l.sel < l.top {
+			l.top = l.sel
+		}
+		if l.height > 0 && l.sel >= l.top+l.height {
+			l.top = l.sel - l.height + 1
+		}
+	} else if l.height > 0 && l.top > n-l.height {
+		l.top = n - l.height
+	}
+	if l.top < 0 {
+		l.top = 0
+	}
+}
+
+// draw draws the list in a box with the title, the border is highlighted
+// when the list has the focus.
+func (l *tuiList) draw(s tcell.Screen, x, y, w, h int, title string, focused bool) {
+	if w < 2 || h < 2 {
+		return
+	}
+	border := tcell.StyleDefault
+	if focused {
+		border = border.Foreground(tcell.ColorYellow).Bold(true)
+	}
+	for i := x + 1; i < x+w-1; i++ {
+		s.SetContent(i, y, tcell.RuneHLine, nil, border)
+		s.SetContent(i, y+h-1, tcell.RuneHLine, nil, border)
+	}
+	for j := y + 1; j < y+h-1; j++ {
+		s.SetContent(x, j, tcell.RuneVLine, nil, border)
+		s.SetContent(x+w-1, j, tcell.RuneVLine, nil, border)
+	}
+	s.SetContent(x, y, tcell.RuneULCorner, nil, border)
+	s.SetContent(x+w-1, y, tcell.RuneURCorner, nil, border)
+	s.SetContent(x, y+h-1, tcell.RuneLLCorner, nil, border)
+	s.SetContent(x+w-1, y+h-1, tcell.RuneLRCorner, nil, border)
+	drawText(s, x+2, y, w-4, border, " "+title+" ")
+
+	l.height = h - 2
+	l.clamp()
+	for i := 0; i < l.height && l.top+i < len(l.lines); i++ {
+		style := tcell.StyleDefault
+		line := l.lines[l.top+i]
+		if l.selectable && l.top+i == l.sel {
+			style = style.Reverse(true)
+			line += strings.Repeat(" ", w)
+		}
+		drawText(s, x+1, y+1+i, w-2, style, line)
+	}
+}
+
+// drawText draws the text on a line, clipped to the width.
+func drawText(s tcell.Screen, x, y, w int, style tcell.Style, text string) {
+	i := 0
+	for _, r := range text {
+		if i >= w {
+			return
+		}
+		s.SetContent(x+i, y, r, nil, style)
+		i++
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+}
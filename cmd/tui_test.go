@@ -0,0 +1,85 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"github.com/P4Networking/proto/go/p4"
+	"testing"
+)
+
+// testMeterTable is a match table with a direct meter and counter.
+const testMeterTable = `{
+	"name": "SwitchIngress.nexthop", "id": 4, "table_type": "MatchAction_Direct", "size": 64,
+	"key": [
+		{"id": 1, "name": "vrf", "match_type": "Exact", "mandatory": true, "type": {"type": "bytes", "width": 8}}
+	],
+	"action_specs": [
+		{"id": 10, "name": "SwitchIngress.set_nexthop", "data": [
+			{"id": 1, "name": "nexthop_id", "mandatory": true, "type": {"type": "bytes", "width": 16}}
+		]}
+	],
+	"data": [
+		{"mandatory": false, "singleton": {"id": 65553, "name": "$COUNTER_SPEC_PKTS", "type": {"type": "uint64", "width": 64}}},
+		{"mandatory": false, "singleton": {"id": 65555, "name": "$METER_SPEC_CIR_KBPS", "type": {"type": "uint64", "width": 64}}}
+	]
+}`
+
+func TestEditTextRoundTrip(t *testing.T) {
+	table := testTable(t, testMeterTable)
+	stream := func(b ...byte) *p4.DataField_Stream { return &p4.DataField_Stream{Stream: b} }
+	data := &p4.TableData{ActionId: 10, Fields: []*p4.DataField{
+		{FieldId: 1, Value: stream(0x00, 0x05)},
+		{FieldId: 65553, Value: stream(0, 0, 0, 0, 0, 0, 0, 9)},
+		{FieldId: 65555, Value: stream(0, 0, 0, 0, 0, 0, 0x03, 0xe8)},
+	}}
+
+	text := entryEditText(table, data)
+	words, err := splitWords(text)
+	if err != nil {
+		t.Fatalf("splitWords(%q): %v", text, err)
+	}
+	got, err := buildEntryData(table, words)
+	if err != nil {
+		t.Fatalf("buildEntryData(%q): %v", text, err)
+	}
+	if got.GetActionId() != 10 {
+		t.Errorf("action ID = %d, want 10", got.GetActionId())
+	}
+	want := map[uint32][]byte{1: {0x00, 0x05}, 65555: {0, 0, 0, 0, 0, 0, 0x03, 0xe8}}
+	if len(got.GetFields()) != len(want) {
+		t.Fatalf("edit %q encodes %d fields, want %d", text, len(got.GetFields()), len(want))
+	}
+	for _, f := range got.GetFields() {
+		if !bytes.Equal(f.GetStream(), want[f.FieldId]) {
+			t.Errorf("field %d = %x, want %x", f.FieldId, f.GetStream(), want[f.FieldId])
+		}
+	}
+}
+
+func TestBuildEditDataErrors(t *testing.T) {
+	table := testTable(t, testMeterTable)
+	tests := [][]string{
+		{"no_such_action"},
+		{"set_nexthop", "nexthop_id=1", "no_such_field=1"},
+		{"set_nexthop", "nexthop_id"},
+	}
+	for _, words := range tests {
+		if _, err := buildEntryData(table, words); err == nil {
+			t.Errorf("buildEntryData(%q) succeeded, want error", words)
+		}
+	}
+}
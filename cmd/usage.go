@@ -0,0 +1,163 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"sort"
+)
+
+var (
+	usageWarn float64
+)
+
+type tableUsage struct {
+	name  string
+	usage uint32
+	size  int
+	fill  float64
+	err   error
+}
+
+// usageCmd represents the usage command
+var usageCmd = &cobra.Command{
+	Use:   "usage [TABLE-NAME...]",
+	Short: "Show the usage and capacity of tables",
+	Long: `Show the number of entries against the size of tables, sorted by fill level.
+With --warn, the tables which exceed the threshold in percent are marked and
+the command exits with non-zero status.
+
+  bfcli usage --warn 80`,
+	ValidArgsFunction: completeTableNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, p4Info, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		var tables []*util.Table
+		if len(args) == 0 {
+			for i := range p4Info.Tables {
+				if p4Info.Tables[i].Size != 0 {
+					tables = append(tables, &p4Info.Tables[i])
+				}
+			}
+		}
+		for _, name := range args {
+			table := findTable(name)
+			if table == nil {
+				fmt.Printf("Can not found table with name: %s\n", name)
+				exit(1)
+			}
+			tables = append(tables, table)
+		}
+		if len(tables) == 0 {
+			fmt.Println("No table to report")
+			return
+		}
+
+		usages := readTableUsages(cli, ctx, tables)
+		sort.SliceStable(usages, func(i, j int) bool {
+			return usages[i].fill > usages[j].fill
+		})
+
+		exceeded := false
+		fmt.Printf("%-50s %10s %10s %8s\n", "Table Name", "Usage", "Size", "Fill")
+		for _, u := range usages {
+			if u.err != nil {
+				fmt.Printf("%-50s %10s %10d %8s\n", u.name, "N/A", u.size, "N/A")
+				continue
+			}
+			mark := ""
+			if usageWarn > 0 && u.fill >= usageWarn {
+				mark = " !"
+				exceeded = true
+			}
+			fmt.Printf("%-50s %10d %10d %7.1f%%%s\n", u.name, u.usage, u.size, u.fill, mark)
+		}
+
+		if exceeded {
+			fmt.Printf("Some tables exceed %.1f%% of their size\n", usageWarn)
+			conn.Close()
+			cancel()
+			exit(1)
+		}
+	},
+}
+
+// readTableUsages reads the usage of all tables in one request. Not every
+// table type supports TableUsage, so on failure every table is read on its
+// own and the unsupported ones are reported individually.
+func readTableUsages(cli p4.BfRuntimeClient, ctx context.Context, tables []*util.Table) []tableUsage {
+	usageEntity := func(t *util.Table) *p4.Entity {
+		return &p4.Entity{
+			Entity: &p4.Entity_TableUsage{
+				TableUsage: &p4.TableUsage{TableId: t.ID},
+			},
+		}
+	}
+
+	usages := make([]tableUsage, len(tables))
+	for i, t := range tables {
+		usages[i] = tableUsage{name: t.Name, size: int(t.Size)}
+	}
+	fill := func(entities []*p4.Entity) {
+		for _, e := range entities {
+			u := e.GetTableUsage()
+			if u == nil {
+				continue
+			}
+			for i, t := range tables {
+				if t.ID == u.GetTableId() {
+					usages[i].usage = u.GetUsage()
+					if usages[i].size != 0 {
+						usages[i].fill = float64(u.GetUsage()) * 100 / float64(usages[i].size)
+					}
+				}
+			}
+		}
+	}
+
+	entities := make([]*p4.Entity, 0, len(tables))
+	for _, t := range tables {
+		entities = append(entities, usageEntity(t))
+	}
+	result, err := tryReadEntities(cli, ctx, entities...)
+	if err == nil {
+		fill(result)
+		return usages
+	}
+
+	for i, t := range tables {
+		result, err := tryReadEntities(cli, ctx, usageEntity(t))
+		if err != nil {
+			usages[i].err = err
+			continue
+		}
+		fill(result)
+	}
+	return usages
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+	usageCmd.Flags().Float64VarP(&usageWarn, "warn", "w", 0, "Warn and exit with non-zero status when fill level in percent reaches the threshold")
+}
@@ -8,35 +8,47 @@ import (
 	"github.com/P4Networking/pisc/util"
 	"github.com/P4Networking/proto/go/p4"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
 	"log"
+	"math/big"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
 	FOUND        = true
 	NOT_FOUND    = false
 	DEFAULT_ADDR = ":50000"
+	DEVICE_ID    = uint32(77)
 	p4Info       util.BfRtInfoStruct
 	nonP4Info    util.BfRtInfoStruct
+
+	// The client of the current connection, for lookups such as the port
+	// map which are loaded on demand.
+	bfrtClient p4.BfRuntimeClient
+	bfrtCtx    context.Context
 )
 
-func initConfigClient() (*p4.BfRuntimeClient, *context.Context, *grpc.ClientConn, context.CancelFunc, *util.BfRtInfoStruct, *util.BfRtInfoStruct) {
-	if server == "" {
-		server = DEFAULT_ADDR
-	}
-	conn, err := grpc.Dial(server, grpc.WithInsecure(), grpc.WithBlock())
-	if err != nil {
-		log.Fatalf("did not connect: %v", err)
+func initConfigClient() (*p4.BfRuntimeClient, *context.Context, io.Closer, context.CancelFunc, *util.BfRtInfoStruct, *util.BfRtInfoStruct) {
+	if shellSession != nil {
+		// The shell keeps the connection open across commands, so closing it
+		// is left to the shell.
+		return &shellSession.cli, &shellSession.ctx, nopCloser{}, func() {}, &p4Info, &nonP4Info
 	}
-
-	cli := p4.NewBfRuntimeClient(conn)
+	cli, ctx, conn, cancel := dialServer()
 
 	// Contact the server and print out its response.
 
-	ctx, cancel := context.WithCancel(context.Background())
-
-	rsp, err := cli.GetForwardingPipelineConfig(ctx, &p4.GetForwardingPipelineConfigRequest{DeviceId: uint32(77)})
+	rsp, err := cli.GetForwardingPipelineConfig(ctx, &p4.GetForwardingPipelineConfigRequest{DeviceId: DEVICE_ID})
 	if err != nil {
-		log.Fatalf("Error with", err)
+		log.Fatalf("Error with %v", err)
 	}
 
 	err = gob.NewDecoder(bytes.NewReader(rsp.Config[0].BfruntimeInfo)).Decode(&p4Info)
@@ -48,9 +60,87 @@ func initConfigClient() (*p4.BfRuntimeClient, *context.Context, *grpc.ClientConn
 	if err != nil {
 		log.Fatal("decode error 2:", err)
 	}
+	bfrtClient, bfrtCtx = cli, ctx
 	return &cli, &ctx, conn, cancel, &p4Info, &nonP4Info
 }
 
+// dialServer connects to the server without fetching the pipeline config,
+// for the commands which work on a device with no program loaded.
+func dialServer() (p4.BfRuntimeClient, context.Context, io.Closer, context.CancelFunc) {
+	if shellSession != nil {
+		return shellSession.cli, shellSession.ctx, nopCloser{}, func() {}
+	}
+	if server == "" {
+		server = DEFAULT_ADDR
+	}
+	return dialAddress(server, 0)
+}
+
+// dialAddress connects to the server at the address, which may differ from
+// the --server one. A zero timeout waits until the server is up.
+func dialAddress(addr string, timeout time.Duration) (p4.BfRuntimeClient, context.Context, io.Closer, context.CancelFunc) {
+	dialCtx := context.Background()
+	if timeout > 0 {
+		var cancelDial context.CancelFunc
+		dialCtx, cancelDial = context.WithTimeout(dialCtx, timeout)
+		defer cancelDial()
+	}
+	conn, err := grpc.DialContext(dialCtx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		fatalf("did not connect: %v", err)
+	}
+
+	cli := p4.NewBfRuntimeClient(conn)
+	ctx, cancel := context.WithCancel(context.Background())
+	return cli, ctx, conn, cancel
+}
+
+// fatalf aborts the command, only the command rather than the process when
+// it runs inside the shell.
+func fatalf(format string, v ...interface{}) {
+	if shellSession != nil {
+		fmt.Printf(format+"\n", v...)
+		panic(shellExit{code: 1})
+	}
+	log.Fatalf(format, v...)
+}
+
+// interruptContext returns a context cancelled by Ctrl+C, for the commands
+// which run until they are stopped. The shell cancels its commands already.
+func interruptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if shellSession != nil {
+		return ctx, cancel
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(interrupt)
+		cancel()
+	}
+}
+
+// isCanceled reports whether an error comes from stopping the command rather
+// than from the server.
+func isCanceled(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || status.Code(err) == codes.Canceled
+}
+
+// exit is os.Exit for commands which can run inside the shell.
+func exit(code int) {
+	if shellSession != nil {
+		panic(shellExit{code: code})
+	}
+	os.Exit(code)
+}
+
 func printNameById(id uint32) bool {
 	var name string
 	var ok bool
@@ -74,3 +164,659 @@ func printNameById(id uint32) bool {
 	}
 	return NOT_FOUND
 }
+
+// fieldInfo is a flattened view of a key field, a data field or an action
+// parameter in BfRtInfo.
+type fieldInfo struct {
+	ID        uint32
+	Name      string
+	MatchType string
+	Type      string
+	Width     int
+	Mandatory bool
+	Repeated  bool
+}
+
+// findTable looks up a table by its full name in the P4 and non-P4 tables,
+// then falls back to guessing when the given name is unambiguous.
+func findTable(name string) *util.Table {
+	for _, info := range []*util.BfRtInfoStruct{&p4Info, &nonP4Info} {
+		for i := range info.Tables {
+			if info.Tables[i].Name == name {
+				return &info.Tables[i]
+			}
+		}
+	}
+	for _, info := range []*util.BfRtInfoStruct{&p4Info, &nonP4Info} {
+		if tableList, ok := info.GuessTableName(name); ok && len(tableList) == 1 {
+			for i := range info.Tables {
+				if info.Tables[i].Name == tableList[0] {
+					return &info.Tables[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func findTableById(id uint32) *util.Table {
+	for _, info := range []*util.BfRtInfoStruct{&p4Info, &nonP4Info} {
+		for i := range info.Tables {
+			if info.Tables[i].ID == id {
+				return &info.Tables[i]
+			}
+		}
+	}
+	return nil
+}
+
+func keyFields(table *util.Table) []fieldInfo {
+	fields := make([]fieldInfo, 0, len(table.Key))
+	for _, k := range table.Key {
+		fields = append(fields, fieldInfo{
+			ID:        uint32(k.ID),
+			Name:      k.Name,
+			MatchType: k.MatchType,
+			Type:      k.Type.Type,
+			Width:     int(k.Type.Width),
+			Mandatory: k.Mandatory,
+			Repeated:  k.Repeated,
+		})
+	}
+	return fields
+}
+
+func dataFields(table *util.Table) []fieldInfo {
+	fields := make([]fieldInfo, 0, len(table.Data))
+	for _, d := range table.Data {
+		fields = append(fields, fieldInfo{
+			ID:        uint32(d.Singleton.ID),
+			Name:      d.Singleton.Name,
+			Type:      d.Singleton.Type.Type,
+			Width:     int(d.Singleton.Type.Width),
+			Mandatory: d.Mandatory,
+			Repeated:  d.Singleton.Repeated,
+		})
+	}
+	return fields
+}
+
+// searchField matches the full field name first, then the last dotted
+// component so "f1" finds "SwitchIngress.reg.f1".
+func searchField(fields []fieldInfo, name string) (fieldInfo, bool) {
+	for _, f := range fields {
+		if f.Name == name {
+			return f, FOUND
+		}
+	}
+	for _, f := range fields {
+		if strings.HasSuffix(f.Name, "."+name) {
+			return f, FOUND
+		}
+	}
+	return fieldInfo{}, NOT_FOUND
+}
+
+func searchFieldById(fields []fieldInfo, id uint32) (fieldInfo, bool) {
+	for _, f := range fields {
+		if f.ID == id {
+			return f, FOUND
+		}
+	}
+	return fieldInfo{}, NOT_FOUND
+}
+
+// bitWidth returns the width of the field, falling back to the width implied
+// by fixed size types which BfRtInfo declares without one.
+func (f fieldInfo) bitWidth() int {
+	if f.Width > 0 {
+		return f.Width
+	}
+	switch f.Type {
+	case "bool":
+		return 1
+	case "uint8":
+		return 8
+	case "uint16":
+		return 16
+	case "uint32":
+		return 32
+	case "uint64":
+		return 64
+	}
+	return 0
+}
+
+// encodeValue converts decimal, hex (0x), IPv4, IPv6 and MAC notations to
+// the big-endian byte stream BfRuntime expects for a field of given width.
+func encodeValue(value string, width int) ([]byte, error) {
+	var raw []byte
+	if ip := net.ParseIP(value); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil && strings.Contains(value, ".") {
+			raw = ip4
+		} else {
+			raw = ip.To16()
+		}
+	} else if mac, err := net.ParseMAC(value); err == nil {
+		raw = mac
+	} else {
+		n, ok := new(big.Int).SetString(value, 0)
+		if !ok || n.Sign() < 0 {
+			return nil, fmt.Errorf("invalid value %q", value)
+		}
+		raw = n.Bytes()
+	}
+
+	if width <= 0 {
+		if len(raw) == 0 {
+			return []byte{0}, nil
+		}
+		return raw, nil
+	}
+	if new(big.Int).SetBytes(raw).BitLen() > width {
+		return nil, fmt.Errorf("value %q does not fit in %d bits", value, width)
+	}
+	size := (width + 7) / 8
+	if len(raw) > size {
+		raw = raw[len(raw)-size:]
+	}
+	buf := make([]byte, size)
+	copy(buf[size-len(raw):], raw)
+	return buf, nil
+}
+
+func decodeValue(b []byte) *big.Int {
+	return new(big.Int).SetBytes(b)
+}
+
+func readEntities(cli p4.BfRuntimeClient, ctx context.Context, entities ...*p4.Entity) []*p4.Entity {
+	result, err := tryReadEntities(cli, ctx, entities...)
+	if err != nil {
+		fatalf("Got error: %v", err)
+	}
+	return result
+}
+
+// tryReadEntities is readEntities for callers which can recover from a
+// failed read.
+func tryReadEntities(cli p4.BfRuntimeClient, ctx context.Context, entities ...*p4.Entity) ([]*p4.Entity, error) {
+	stream, err := cli.Read(ctx, &p4.ReadRequest{Entities: entities})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*p4.Entity
+	for {
+		rsp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rsp.GetEntities()...)
+	}
+	return result, nil
+}
+
+func writeUpdates(cli p4.BfRuntimeClient, ctx context.Context, updates ...*p4.Update) error {
+	_, err := cli.Write(ctx, &p4.WriteRequest{Updates: updates})
+	return err
+}
+
+// syncTable runs a table operation such as SyncRegisters or SyncCounters
+// which pulls the hardware state into the software shadow.
+func syncTable(cli p4.BfRuntimeClient, ctx context.Context, tableId uint32, operation string) error {
+	return writeUpdates(cli, ctx, &p4.Update{
+		Type: p4.Update_INSERT,
+		Entity: &p4.Entity{
+			Entity: &p4.Entity_TableOperation{
+				TableOperation: &p4.TableOperation{
+					TableId:             tableId,
+					TableOperationsType: operation,
+				},
+			},
+		},
+	})
+}
+
+// collectDataValues gathers every value returned for a data field. Repeated
+// fields such as register data come back with one element per pipe, either
+// as repeated DataFields or as an int array.
+func collectDataValues(fields []*p4.DataField, id uint32) []*big.Int {
+	var values []*big.Int
+	for _, d := range fields {
+		if d.FieldId != id {
+			continue
+		}
+		switch d.GetValue().(type) {
+		case *p4.DataField_IntArrVal:
+			for _, v := range d.GetIntArrVal().GetVal() {
+				values = append(values, new(big.Int).SetUint64(uint64(v)))
+			}
+		case *p4.DataField_BoolArrVal:
+			for _, v := range d.GetBoolArrVal().GetVal() {
+				if v {
+					values = append(values, big.NewInt(1))
+				} else {
+					values = append(values, big.NewInt(0))
+				}
+			}
+		case *p4.DataField_BoolVal:
+			if d.GetBoolVal() {
+				values = append(values, big.NewInt(1))
+			} else {
+				values = append(values, big.NewInt(0))
+			}
+		default:
+			values = append(values, decodeValue(d.GetStream()))
+		}
+	}
+	return values
+}
+
+// parseAssignment splits a "name=value" argument.
+func parseAssignment(arg string) (string, string, error) {
+	kv := strings.SplitN(arg, "=", 2)
+	if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+		return "", "", fmt.Errorf("invalid argument %q, expect name=value", arg)
+	}
+	return kv[0], kv[1], nil
+}
+
+// buildTableKey encodes match key arguments according to the match type of
+// each key field:
+//
+//	exact:   name=value
+//	lpm:     name=value/prefix-len
+//	ternary: name=value&&&mask
+//	range:   name=low..high
+func buildTableKey(table *util.Table, args []string) (*p4.TableKey, error) {
+	keys := keyFields(table)
+	tableKey := &p4.TableKey{}
+	for _, arg := range args {
+		name, value, err := parseAssignment(arg)
+		if err != nil {
+			return nil, err
+		}
+		field, ok := searchField(keys, name)
+		if !ok {
+			return nil, fmt.Errorf("can not found key field %s in table %s", name, table.Name)
+		}
+		keyField, err := encodeKeyField(field, value)
+		if err != nil {
+			return nil, err
+		}
+		tableKey.Fields = append(tableKey.Fields, keyField)
+	}
+	for _, k := range keys {
+		if !k.Mandatory {
+			continue
+		}
+		found := false
+		for _, f := range tableKey.Fields {
+			if f.FieldId == k.ID {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("the key field %s is mandatory", k.Name)
+		}
+	}
+	return tableKey, nil
+}
+
+func encodeKeyField(field fieldInfo, value string) (*p4.KeyField, error) {
+	width := field.bitWidth()
+	keyField := &p4.KeyField{FieldId: field.ID}
+	switch field.MatchType {
+	case "Exact":
+		value, err := translatePortValue(field, value)
+		if err != nil {
+			return nil, err
+		}
+		v := []byte(value)
+		if field.Type != "string" {
+			var err error
+			if v, err = encodeValue(value, width); err != nil {
+				return nil, err
+			}
+		}
+		keyField.MatchType = &p4.KeyField_Exact_{Exact: &p4.KeyField_Exact{Value: v}}
+	case "LPM":
+		prefixLen := width
+		if i := strings.LastIndex(value, "/"); i >= 0 {
+			n, err := strconv.Atoi(value[i+1:])
+			if err != nil || n < 0 || n > width {
+				return nil, fmt.Errorf("invalid prefix length in %q", value)
+			}
+			prefixLen, value = n, value[:i]
+		}
+		v, err := encodeValue(value, width)
+		if err != nil {
+			return nil, err
+		}
+		keyField.MatchType = &p4.KeyField_Lpm{Lpm: &p4.KeyField_LPM{Value: v, PrefixLen: int32(prefixLen)}}
+	case "Ternary":
+		mask := strings.Repeat("f", (width+3)/4)
+		if i := strings.Index(value, "&&&"); i >= 0 {
+			value, mask = value[:i], value[i+3:]
+		} else {
+			mask = "0x" + mask
+		}
+		v, err := encodeValue(value, width)
+		if err != nil {
+			return nil, err
+		}
+		m, err := encodeValue(mask, width)
+		if err != nil {
+			return nil, err
+		}
+		keyField.MatchType = &p4.KeyField_Ternary_{Ternary: &p4.KeyField_Ternary{Value: v, Mask: m}}
+	case "Range":
+		bounds := strings.SplitN(value, "..", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid range %q, expect low..high", value)
+		}
+		low, err := encodeValue(bounds[0], width)
+		if err != nil {
+			return nil, err
+		}
+		high, err := encodeValue(bounds[1], width)
+		if err != nil {
+			return nil, err
+		}
+		keyField.MatchType = &p4.KeyField_Range_{Range: &p4.KeyField_Range{Low: low, High: high}}
+	default:
+		return nil, fmt.Errorf("unsupported match type %s of key field %s", field.MatchType, field.Name)
+	}
+	return keyField, nil
+}
+
+// formatKeyField renders a key field in the syntax accepted by buildTableKey,
+// port fields show their front-panel name and device port.
+func formatKeyField(name string, f *p4.KeyField) string {
+	switch f.GetMatchType().(type) {
+	case *p4.KeyField_Exact_:
+		if port, ok := formatPortValue(name, f.GetExact().GetValue()); ok {
+			return fmt.Sprintf("%s=%s", name, port)
+		}
+		return fmt.Sprintf("%s=0x%x", name, f.GetExact().GetValue())
+	case *p4.KeyField_Ternary_:
+		return fmt.Sprintf("%s=0x%x&&&0x%x", name, f.GetTernary().GetValue(), f.GetTernary().GetMask())
+	case *p4.KeyField_Lpm:
+		return fmt.Sprintf("%s=0x%x/%d", name, f.GetLpm().GetValue(), f.GetLpm().GetPrefixLen())
+	case *p4.KeyField_Range_:
+		return fmt.Sprintf("%s=0x%x..0x%x", name, f.GetRange().GetLow(), f.GetRange().GetHigh())
+	}
+	return fmt.Sprintf("%s=?", name)
+}
+
+// formatTableKey renders the whole key of an entry, ordered by field ID so
+// that equal keys give equal strings.
+func formatTableKey(table *util.Table, key *p4.TableKey) string {
+	if key == nil || len(key.GetFields()) == 0 {
+		return "(default)"
+	}
+	fields := append([]*p4.KeyField{}, key.GetFields()...)
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].FieldId < fields[j].FieldId
+	})
+	keys := keyFields(table)
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		name := fmt.Sprintf("%d", f.FieldId)
+		if k, ok := searchFieldById(keys, f.FieldId); ok {
+			name = k.Name
+		}
+		parts = append(parts, formatKeyField(name, f))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatDataField renders a data field value in hex, repeated values are
+// joined by commas.
+func formatDataField(d *p4.DataField) string {
+	switch d.GetValue().(type) {
+	case *p4.DataField_Stream:
+		return fmt.Sprintf("0x%x", d.GetStream())
+	case *p4.DataField_FloatVal:
+		return fmt.Sprintf("%v", d.GetFloatVal())
+	case *p4.DataField_StrVal:
+		return d.GetStrVal()
+	case *p4.DataField_BoolVal:
+		return fmt.Sprintf("%t", d.GetBoolVal())
+	case *p4.DataField_IntArrVal:
+		return strings.Trim(fmt.Sprint(d.GetIntArrVal().GetVal()), "[]")
+	case *p4.DataField_BoolArrVal:
+		return strings.Trim(fmt.Sprint(d.GetBoolArrVal().GetVal()), "[]")
+	case *p4.DataField_StrArrVal:
+		return strings.Join(d.GetStrArrVal().GetVal(), ",")
+	}
+	return ""
+}
+
+// actionInfo is a flattened view of an action spec of a table.
+type actionInfo struct {
+	ID     uint32
+	Name   string
+	Params []fieldInfo
+}
+
+func actionSpecs(table *util.Table) []actionInfo {
+	actions := make([]actionInfo, 0, len(table.ActionSpecs))
+	for _, a := range table.ActionSpecs {
+		action := actionInfo{ID: uint32(a.ID), Name: a.Name}
+		for _, d := range a.Data {
+			action.Params = append(action.Params, fieldInfo{
+				ID:        uint32(d.ID),
+				Name:      d.Name,
+				Type:      d.Type.Type,
+				Width:     int(d.Type.Width),
+				Mandatory: d.Mandatory,
+				Repeated:  d.Repeated,
+			})
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// searchAction matches the full action name first, then the last dotted
+// component like searchField.
+func searchAction(table *util.Table, name string) (actionInfo, bool) {
+	actions := actionSpecs(table)
+	for _, a := range actions {
+		if a.Name == name {
+			return a, FOUND
+		}
+	}
+	for _, a := range actions {
+		if strings.HasSuffix(a.Name, "."+name) {
+			return a, FOUND
+		}
+	}
+	return actionInfo{}, NOT_FOUND
+}
+
+func searchActionById(table *util.Table, id uint32) (actionInfo, bool) {
+	for _, a := range actionSpecs(table) {
+		if a.ID == id {
+			return a, FOUND
+		}
+	}
+	return actionInfo{}, NOT_FOUND
+}
+
+// dataFieldName names a data field of an entry, which is either a parameter
+// of the entry's action or a data field of the table.
+func dataFieldName(table *util.Table, actionId, fieldId uint32) string {
+	if a, ok := searchActionById(table, actionId); ok {
+		if f, ok := searchFieldById(a.Params, fieldId); ok {
+			return f.Name
+		}
+	}
+	if f, ok := searchFieldById(dataFields(table), fieldId); ok {
+		return f.Name
+	}
+	return fmt.Sprintf("%d", fieldId)
+}
+
+// encodeDataField encodes a data field value according to its type. The
+// values of repeated fields are separated by commas.
+func encodeDataField(field fieldInfo, value string) (*p4.DataField, error) {
+	dataField := &p4.DataField{FieldId: field.ID}
+	switch {
+	case field.Repeated && field.Type == "bool":
+		var values []bool
+		for _, v := range strings.Split(value, ",") {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q of %s", v, field.Name)
+			}
+			values = append(values, b)
+		}
+		dataField.Value = &p4.DataField_BoolArrVal{BoolArrVal: &p4.DataField_BoolArray{Val: values}}
+	case field.Repeated:
+		var values []uint32
+		for _, v := range strings.Split(value, ",") {
+			n, err := strconv.ParseUint(v, 0, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q of %s", v, field.Name)
+			}
+			values = append(values, uint32(n))
+		}
+		dataField.Value = &p4.DataField_IntArrVal{IntArrVal: &p4.DataField_IntArray{Val: values}}
+	case field.Type == "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q of %s", value, field.Name)
+		}
+		dataField.Value = &p4.DataField_BoolVal{BoolVal: b}
+	case field.Type == "string":
+		dataField.Value = &p4.DataField_StrVal{StrVal: value}
+	default:
+		value, err := translatePortValue(field, value)
+		if err != nil {
+			return nil, err
+		}
+		stream, err := encodeValue(value, field.bitWidth())
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %v", field.Name, err)
+		}
+		dataField.Value = &p4.DataField_Stream{Stream: stream}
+	}
+	return dataField, nil
+}
+
+// buildActionData encodes "name=value" parameters of an action of the table.
+func buildActionData(table *util.Table, actionName string, params []string) (*p4.TableData, error) {
+	action, ok := searchAction(table, actionName)
+	if !ok {
+		return nil, fmt.Errorf("can not found action %s in table %s", actionName, table.Name)
+	}
+	data := &p4.TableData{ActionId: action.ID}
+	for _, p := range params {
+		name, value, err := parseAssignment(p)
+		if err != nil {
+			return nil, err
+		}
+		field, ok := searchField(action.Params, name)
+		if !ok {
+			return nil, fmt.Errorf("can not found parameter %s in action %s", name, action.Name)
+		}
+		dataField, err := encodeDataField(field, value)
+		if err != nil {
+			return nil, err
+		}
+		data.Fields = append(data.Fields, dataField)
+	}
+	for _, p := range action.Params {
+		if !p.Mandatory {
+			continue
+		}
+		found := false
+		for _, f := range data.Fields {
+			if f.FieldId == p.ID {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("the parameter %s of action %s is mandatory", p.Name, action.Name)
+		}
+	}
+	return data, nil
+}
+
+// buildTableData encodes "name=value" assignments of table data fields such
+// as $ACTION_MEMBER_ID or $MAX_GROUP_SIZE.
+func buildTableData(table *util.Table, assignments []string) (*p4.TableData, error) {
+	fields := dataFields(table)
+	data := &p4.TableData{}
+	for _, a := range assignments {
+		name, value, err := parseAssignment(a)
+		if err != nil {
+			return nil, err
+		}
+		field, ok := searchField(fields, name)
+		if !ok {
+			return nil, fmt.Errorf("can not found data field %s in table %s", name, table.Name)
+		}
+		dataField, err := encodeDataField(field, value)
+		if err != nil {
+			return nil, err
+		}
+		data.Fields = append(data.Fields, dataField)
+	}
+	return data, nil
+}
+
+// buildEntryData encodes "ACTION NAME=VALUE..." as taken by the edit prompt of
+// the terminal UI and by import. On tables with actions the assignments to
+// table data fields, such as direct meters and registers, are encoded apart
+// from the action parameters and merged into the action data.
+func buildEntryData(table *util.Table, words []string) (*p4.TableData, error) {
+	if len(actionSpecs(table)) == 0 || len(words) == 0 {
+		return buildTableData(table, words)
+	}
+	action, ok := searchAction(table, words[0])
+	if !ok {
+		return nil, fmt.Errorf("can not found action %s in table %s", words[0], table.Name)
+	}
+	var params, assignments []string
+	for _, w := range words[1:] {
+		name, _, err := parseAssignment(w)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := searchField(action.Params, name); !ok {
+			if _, ok := searchField(dataFields(table), name); ok {
+				assignments = append(assignments, w)
+				continue
+			}
+		}
+		params = append(params, w)
+	}
+	data, err := buildActionData(table, words[0], params)
+	if err != nil {
+		return nil, err
+	}
+	tableData, err := buildTableData(table, assignments)
+	if err != nil {
+		return nil, err
+	}
+	data.Fields = append(data.Fields, tableData.Fields...)
+	return data, nil
+}
+
+func tableEntryEntity(table *util.Table, key *p4.TableKey, data *p4.TableData) *p4.Entity {
+	return &p4.Entity{
+		Entity: &p4.Entity_TableEntry{
+			TableEntry: &p4.TableEntry{
+				TableId: table.ID,
+				Key:     key,
+				Data:    data,
+			},
+		},
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"testing"
+)
+
+// testTable builds a table from its bf-rt.json description.
+func testTable(t *testing.T, description string) *util.Table {
+	t.Helper()
+	table := &util.Table{}
+	if err := json.Unmarshal([]byte(description), table); err != nil {
+		t.Fatalf("invalid table description: %v", err)
+	}
+	return table
+}
+
+// testLpmTable is an LPM table with a port parameter, shared by the tests.
+const testLpmTable = `{
+	"name": "SwitchIngress.ipv4_lpm", "id": 1, "table_type": "MatchAction_Direct", "size": 1024,
+	"key": [
+		{"id": 1, "name": "hdr.ipv4.dst_addr", "match_type": "LPM", "mandatory": true, "type": {"type": "bytes", "width": 32}},
+		{"id": 2, "name": "vrf", "match_type": "Exact", "mandatory": false, "type": {"type": "bytes", "width": 8}}
+	],
+	"action_specs": [
+		{"id": 10, "name": "SwitchIngress.set_nexthop", "data": [
+			{"id": 1, "name": "port", "mandatory": true, "type": {"type": "bytes", "width": 9}},
+			{"id": 2, "name": "dmac", "mandatory": true, "type": {"type": "bytes", "width": 48}}
+		]},
+		{"id": 11, "name": "SwitchIngress.drop", "data": []}
+	],
+	"data": [
+		{"mandatory": false, "singleton": {"id": 65553, "name": "$COUNTER_SPEC_PKTS", "type": {"type": "uint64"}}}
+	]
+}`
+
+func TestEncodeValue(t *testing.T) {
+	tests := []struct {
+		value   string
+		width   int
+		want    []byte
+		wantErr bool
+	}{
+		{"10", 16, []byte{0, 10}, false},
+		{"0x1ff", 9, []byte{1, 0xff}, false},
+		{"0x200", 9, nil, true},
+		{"10.0.0.1", 32, []byte{10, 0, 0, 1}, false},
+		{"00:11:22:33:44:55", 48, []byte{0, 0x11, 0x22, 0x33, 0x44, 0x55}, false},
+		{"::1", 128, append(make([]byte, 15), 1), false},
+		{"0", 0, []byte{0}, false},
+		{"-1", 8, nil, true},
+		{"abc", 8, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := encodeValue(tt.value, tt.width)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("encodeValue(%q, %d) error = %v, want error %t", tt.value, tt.width, err, tt.wantErr)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("encodeValue(%q, %d) = %x, want %x", tt.value, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeKeyField(t *testing.T) {
+	tests := []struct {
+		field   fieldInfo
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{testField("Exact", 16), "10", "exact:000a", false},
+		{testField("LPM", 32), "10.0.0.0/8", "lpm:0a000000/8", false},
+		{testField("LPM", 32), "10.0.0.1", "lpm:0a000001/32", false},
+		{testField("LPM", 32), "10.0.0.0/33", "", true},
+		{testField("Ternary", 8), "0x10&&&0xf0", "ternary:10&f0", false},
+		{testField("Ternary", 12), "1", "ternary:0001&0fff", false},
+		{testField("Range", 16), "10..20", "range:000a..0014", false},
+		{testField("Range", 16), "10", "", true},
+		{testField("Optional", 16), "10", "", true},
+	}
+	for _, tt := range tests {
+		got, err := encodeKeyField(tt.field, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("encodeKeyField(%s, %q) error = %v, want error %t", tt.field.MatchType, tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil && describeKeyField(got) != tt.want {
+			t.Errorf("encodeKeyField(%s, %q) = %s, want %s", tt.field.MatchType, tt.value, describeKeyField(got), tt.want)
+		}
+	}
+}
+
+func TestBuildTableKey(t *testing.T) {
+	table := testTable(t, testLpmTable)
+	tests := []struct {
+		args    []string
+		fields  int
+		wantErr bool
+	}{
+		{[]string{"dst_addr=10.0.0.0/8"}, 1, false},
+		{[]string{"dst_addr=10.0.0.0/8", "vrf=1"}, 2, false},
+		{[]string{"vrf=1"}, 0, true},
+		{[]string{"dst_addr"}, 0, true},
+		{[]string{"dst_addr=10.0.0.0/8", "nexthop=1"}, 0, true},
+	}
+	for _, tt := range tests {
+		key, err := buildTableKey(table, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("buildTableKey(%v) error = %v, want error %t", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && len(key.Fields) != tt.fields {
+			t.Errorf("buildTableKey(%v) has %d fields, want %d", tt.args, len(key.Fields), tt.fields)
+		}
+	}
+}
+
+// testField describes a key field of the given match type and width.
+func testField(matchType string, width int) fieldInfo {
+	return fieldInfo{ID: 1, Name: "f", MatchType: matchType, Type: "bytes", Width: width}
+}
+
+// describeKeyField renders the match of a key field compactly for comparison.
+func describeKeyField(f *p4.KeyField) string {
+	switch m := f.GetMatchType().(type) {
+	case *p4.KeyField_Exact_:
+		return fmt.Sprintf("exact:%x", m.Exact.Value)
+	case *p4.KeyField_Lpm:
+		return fmt.Sprintf("lpm:%x/%d", m.Lpm.Value, m.Lpm.PrefixLen)
+	case *p4.KeyField_Ternary_:
+		return fmt.Sprintf("ternary:%x&%x", m.Ternary.Value, m.Ternary.Mask)
+	case *p4.KeyField_Range_:
+		return fmt.Sprintf("range:%x..%x", m.Range.Low, m.Range.High)
+	}
+	return ""
+}
+
+func TestSearchField(t *testing.T) {
+	keys := keyFields(testTable(t, testLpmTable))
+	tests := []struct {
+		name  string
+		want  uint32
+		found bool
+	}{
+		{"hdr.ipv4.dst_addr", 1, true},
+		{"dst_addr", 1, true},
+		{"vrf", 2, true},
+		{"addr", 0, false},
+	}
+	for _, tt := range tests {
+		f, ok := searchField(keys, tt.name)
+		if ok != tt.found || f.ID != tt.want {
+			t.Errorf("searchField(%q) = %d, %t, want %d, %t", tt.name, f.ID, ok, tt.want, tt.found)
+		}
+	}
+}
+
+func TestCollectDataValues(t *testing.T) {
+	fields := []*p4.DataField{
+		{FieldId: 1, Value: &p4.DataField_Stream{Stream: []byte{1, 0}}},
+		{FieldId: 1, Value: &p4.DataField_Stream{Stream: []byte{2}}},
+		{FieldId: 2, Value: &p4.DataField_IntArrVal{IntArrVal: &p4.DataField_IntArray{Val: []uint32{3, 4}}}},
+		{FieldId: 3, Value: &p4.DataField_BoolVal{BoolVal: true}},
+	}
+	tests := []struct {
+		id   uint32
+		want []int64
+	}{
+		{1, []int64{256, 2}},
+		{2, []int64{3, 4}},
+		{3, []int64{1}},
+		{4, nil},
+	}
+	for _, tt := range tests {
+		got := collectDataValues(fields, tt.id)
+		if len(got) != len(tt.want) {
+			t.Errorf("collectDataValues(%d) = %v, want %v", tt.id, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i].Int64() != tt.want[i] {
+				t.Errorf("collectDataValues(%d) = %v, want %v", tt.id, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestIsCanceled(t *testing.T) {
+	live := context.Background()
+	stopped, cancel := context.WithCancel(live)
+	cancel()
+	failure := errors.New("connection reset")
+	if isCanceled(live, failure) {
+		t.Errorf("isCanceled with a live context and %v = true", failure)
+	}
+	if !isCanceled(stopped, failure) {
+		t.Errorf("isCanceled with a cancelled context = false")
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2020 Chun Ming Ou <breezestars@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/P4Networking/pisc/util"
+	"github.com/P4Networking/proto/go/p4"
+	"github.com/spf13/cobra"
+	"sort"
+	"strings"
+)
+
+// The data fields which change on their own and therefore differ between
+// software shadow and hardware without being inconsistent.
+var volatileDataFields = []string{"$COUNTER_SPEC_BYTES", "$COUNTER_SPEC_PKTS", "$ENTRY_HIT_STATE", "$ENTRY_TTL"}
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify TABLE-NAME",
+	Args:  cobra.ExactArgs(1),
+	Short: "Compare the flows in software shadow with hardware",
+	Long: `Read the flows of a table from the software shadow of driver and from the
+hardware, then report the flows which exist on one side only or have different
+action data. Counters, TTL and hit state are not compared.`,
+	ValidArgsFunction: completeTableName,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliAddr, ctxAddr, conn, cancel, _, _ := initConfigClient()
+		defer conn.Close()
+		defer cancel()
+		cli := *cliAddr
+		ctx := *ctxAddr
+
+		table := findTable(args[0])
+		if table == nil {
+			fmt.Printf("Can not found table with name: %s\n", args[0])
+			exit(1)
+		}
+
+		read := func(fromHw bool) map[string]string {
+			entries := map[string]string{}
+			for _, e := range readEntities(cli, ctx, &p4.Entity{
+				Entity: &p4.Entity_TableEntry{
+					TableEntry: &p4.TableEntry{
+						TableId:       table.ID,
+						TableReadFlag: &p4.TableReadFlag{FromHw: fromHw},
+					},
+				},
+			}) {
+				if tbl := e.GetTableEntry(); tbl != nil {
+					entries[formatTableKey(table, tbl.GetKey())] = formatEntryData(table, tbl.GetData())
+				}
+			}
+			return entries
+		}
+		sw := read(false)
+		hw := read(true)
+
+		var keys []string
+		for k := range sw {
+			keys = append(keys, k)
+		}
+		for k := range hw {
+			if _, ok := sw[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		mismatch := 0
+		for _, k := range keys {
+			swData, inSw := sw[k]
+			hwData, inHw := hw[k]
+			switch {
+			case !inHw:
+				fmt.Printf("Only in software: %s\n  %s\n", k, swData)
+			case !inSw:
+				fmt.Printf("Only in hardware: %s\n  %s\n", k, hwData)
+			case swData != hwData:
+				fmt.Printf("Data mismatch: %s\n  software: %s\n  hardware: %s\n", k, swData, hwData)
+			default:
+				continue
+			}
+			mismatch++
+		}
+
+		fmt.Printf("Software: %d flows, Hardware: %d flows, Mismatch: %d\n", len(sw), len(hw), mismatch)
+		if mismatch != 0 {
+			conn.Close()
+			cancel()
+			exit(1)
+		}
+	},
+}
+
+// formatEntryData renders the action and the non-volatile data fields of an
+// entry ordered by field ID.
+func formatEntryData(table *util.Table, data *p4.TableData) string {
+	if data == nil {
+		return ""
+	}
+	action := fmt.Sprintf("%d", data.GetActionId())
+	if a, ok := searchActionById(table, data.GetActionId()); ok {
+		action = a.Name
+	}
+
+	tableData := dataFields(table)
+	fields := append([]*p4.DataField{}, data.GetFields()...)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].FieldId < fields[j].FieldId
+	})
+	parts := []string{action}
+	for _, d := range fields {
+		if f, ok := searchFieldById(tableData, d.FieldId); ok && isVolatileField(f.Name) {
+			continue
+		}
+		name := dataFieldName(table, data.GetActionId(), d.FieldId)
+		if port, ok := formatPortValue(name, d.GetStream()); ok {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, port))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", name, formatDataField(d)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func isVolatileField(name string) bool {
+	for _, v := range volatileDataFields {
+		if name == v {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}